@@ -0,0 +1,41 @@
+package common
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ClassifyError returns the job error to show to users for an error returned by a job
+//
+// Errors that are not a types.JobError are classified from known Discord errors, falling back to an internal error
+// as their message may contain internal details
+func ClassifyError(err error) *types.JobError {
+	if err == nil {
+		return nil
+	}
+
+	var jobErr *types.JobError
+	if errors.As(err, &jobErr) {
+		return jobErr
+	}
+
+	var rlErr *discordgo.RateLimitError
+	if errors.As(err, &rlErr) {
+		return types.NewJobError(types.JobErrorCodeRateLimited, "discord is rate limiting the bot, please try again later", err)
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) {
+		switch {
+		case restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeMissingPermissions:
+			return types.NewJobError(types.JobErrorCodeMissingPermissions, "the bot is missing permissions needed to do this", err)
+		case restErr.Response != nil && restErr.Response.StatusCode == http.StatusTooManyRequests:
+			return types.NewJobError(types.JobErrorCodeRateLimited, "discord is rate limiting the bot, please try again later", err)
+		}
+	}
+
+	return types.NewJobError(types.JobErrorCodeInternal, "an internal error occurred", err)
+}
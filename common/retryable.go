@@ -0,0 +1,50 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/minio/minio-go/v7"
+)
+
+// IsRetryableError returns whether or not an error is likely transient, such as a 5xx or rate limit
+// from Discord, an object storage outage or a network timeout
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rlErr *discordgo.RateLimitError
+	if errors.As(err, &rlErr) {
+		return true
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) {
+		return restErr.Response != nil && isRetryableStatus(restErr.Response.StatusCode)
+	}
+
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		return isRetryableStatus(minioErr.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
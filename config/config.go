@@ -7,6 +7,7 @@ type Config struct {
 	Meta          Meta                `yaml:"meta" validate:"required"`
 	ObjectStorage ObjectStorageConfig `yaml:"object_storage" validate:"required"`
 	BasePorts     BasePorts           `yaml:"base_ports" validate:"required"`
+	Jobserver     Jobserver           `yaml:"jobserver" validate:"required"`
 }
 
 type DiscordAuth struct {
@@ -58,3 +59,17 @@ type BasePorts struct {
 	TemplateWorkerAddr string `yaml:"template_worker_addr" default:"http://localhost" comment:"Template Worker Address" validate:"required"`
 	TemplateWorkerPort int    `yaml:"template_worker_port" default:"60000" comment:"Template Worker Port" validate:"required"`
 }
+
+// Settings for the jobserver itself. When upgrading from a config without this section, only shared_secret has
+// to be added, everything else is optional or has a default
+type Jobserver struct {
+	SharedSecret            string         `yaml:"shared_secret" comment:"Shared secret used to sign requests to the jobserver RPC API" validate:"required"`
+	SignatureMaxAgeSecs     int            `yaml:"signature_max_age_secs" default:"60" comment:"How old (in seconds) a signed request may be before it is rejected, nonces are kept in postgres for as long to reject replays on every node"`
+	IdempotencyWindowSecs   int            `yaml:"idempotency_window_secs" default:"86400" comment:"How long (in seconds) a spawn idempotency key maps to the job it created"`
+	ShutdownGracePeriodSecs int            `yaml:"shutdown_grace_period_secs" default:"60" comment:"How long (in seconds) running jobs may take to stop on shutdown before they are cancelled"`
+	Workers                 int            `yaml:"workers" default:"4" comment:"Maximum number of jobs executing at once"`
+	LeaseDurationSecs       int            `yaml:"lease_duration_secs" default:"60" comment:"How long (in seconds) a node may go without renewing the leases of its jobs before other nodes take them over"`
+	JobConcurrency          map[string]int `yaml:"job_concurrency" comment:"Maximum number of jobs of a given name (e.g. guild_create_backup) executing at once"`
+	CallbackSecret          string         `yaml:"callback_secret" comment:"(optional) Secret used to sign job callbacks, must be different from shared_secret. Spawns with a callback url are rejected if unset"`
+	CallbackAllowedHosts    []string       `yaml:"callback_allowed_hosts" comment:"If set, job callbacks may only be sent to these hosts"`
+}
@@ -41,6 +41,6 @@ type PresetInfo struct {
 	// The default options/data
 	Preset JobImpl
 
-	// Any comments for specific fields
+	// Any comments for specific fields, keyed by Go field path (e.g. Options.BackupMessages or Constraints.Create.TotalMaxMessages)
 	Comments map[string]string
 }
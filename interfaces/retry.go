@@ -0,0 +1,70 @@
+package interfaces
+
+import (
+	"time"
+)
+
+// RetryPolicy controls how failed executions of a job are retried
+type RetryPolicy struct {
+	// The maximum number of times the job is executed, including the first attempt
+	MaxAttempts int
+
+	// How long to wait before the first retry
+	InitialBackoff time.Duration
+
+	// The backoff is multiplied by this after every attempt, defaults to 2
+	Multiplier float64
+
+	// The maximum backoff between attempts, if set
+	MaxBackoff time.Duration
+
+	// IsRetryable returns whether or not an error is transient and the job should be retried,
+	// if unset common.IsRetryableError is used
+	IsRetryable func(err error) bool
+}
+
+// DefaultRetryPolicy retries transient failures (such as Discord or object storage being unavailable or
+// rate limiting) a couple of times, jobs implementing RetryableJobImpl should use this unless they need otherwise
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 30 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+}
+
+// ShouldRetry returns whether or not a job may be retried after the given (1-indexed) attempt failed
+func (p RetryPolicy) ShouldRetry(attempt int) bool {
+	return attempt < p.MaxAttempts
+}
+
+// Backoff returns how long to wait before retrying after the given (1-indexed) attempt failed
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	backoff := float64(p.InitialBackoff)
+
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+
+	return time.Duration(backoff)
+}
+
+// RetryableJobImpl may optionally be implemented by jobs whose failed executions should be retried
+type RetryableJobImpl interface {
+	JobImpl
+
+	// RetryPolicy returns the retry policy of the job
+	RetryPolicy() RetryPolicy
+}
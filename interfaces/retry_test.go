@@ -0,0 +1,57 @@
+package interfaces
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{"attempt 0", RetryPolicy{InitialBackoff: time.Second}, 0, time.Second},
+		{"first attempt", RetryPolicy{InitialBackoff: time.Second}, 1, time.Second},
+		{"doubles by default", RetryPolicy{InitialBackoff: time.Second}, 2, 2 * time.Second},
+		{"keeps growing", RetryPolicy{InitialBackoff: time.Second}, 4, 8 * time.Second},
+		{"custom multiplier", RetryPolicy{InitialBackoff: time.Second, Multiplier: 3}, 3, 9 * time.Second},
+		{"fractional multiplier", RetryPolicy{InitialBackoff: time.Second, Multiplier: 1.5}, 3, 2250 * time.Millisecond},
+		{"below the cap", RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}, 3, 4 * time.Second},
+		{"capped", RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}, 5, 10 * time.Second},
+		{"capped far past the cap", RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}, 1000, 10 * time.Second},
+		{"initial backoff above the cap", RetryPolicy{InitialBackoff: time.Minute, MaxBackoff: 10 * time.Second}, 1, 10 * time.Second},
+		{"default policy", DefaultRetryPolicy, 2, time.Minute},
+		{"default policy capped", DefaultRetryPolicy, 10, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("%s: Backoff(%d) = %s, want %s", tt.name, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	tests := []struct {
+		maxAttempts int
+		attempt     int
+		want        bool
+	}{
+		{3, 1, true},
+		{3, 2, true},
+		{3, 3, false},
+		{3, 4, false},
+		{1, 1, false},
+		{0, 1, false},
+	}
+
+	for _, tt := range tests {
+		policy := RetryPolicy{MaxAttempts: tt.maxAttempts}
+
+		if got := policy.ShouldRetry(tt.attempt); got != tt.want {
+			t.Errorf("MaxAttempts %d: ShouldRetry(%d) = %v, want %v", tt.maxAttempts, tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"time"
+)
+
+// TimeoutJobImpl may optionally be implemented by jobs that need a different execution timeout than the default
+type TimeoutJobImpl interface {
+	JobImpl
+
+	// Timeout returns how long a single execution of the job may take, 0 uses the default timeout
+	//
+	// Resumable jobs get the full timeout again every time they are resumed
+	Timeout() time.Duration
+}
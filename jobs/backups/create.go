@@ -173,6 +173,19 @@ func (t *ServerBackupCreate) Expiry() *time.Duration {
 	return nil
 }
 
+func (t *ServerBackupCreate) Timeout() time.Duration {
+	if t.Constraints == nil || t.Constraints.Create == nil {
+		return 0
+	}
+
+	return time.Duration(t.Constraints.Create.Timeout)
+}
+
+// Backups are not resumable, so a retry starts over from scratch
+func (t *ServerBackupCreate) RetryPolicy() interfaces.RetryPolicy {
+	return interfaces.DefaultRetryPolicy
+}
+
 func (t *ServerBackupCreate) Resumable() bool {
 	return false
 }
@@ -180,7 +193,14 @@ func (t *ServerBackupCreate) Resumable() bool {
 func (t *ServerBackupCreate) Validate(state jobstate.State) error {
 	opMode := state.OperationMode()
 	if opMode == "jobs" {
-		t.Constraints = FreePlanBackupConstraints // TODO: Add other constraint types based on plans once we have them
+		var constraints BackupConstraints
+		err := state.ConstraintProvider().Resolve(state.Context(), state.GuildID(), ConstraintKind, FreePlanBackupConstraints, &constraints)
+
+		if err != nil {
+			return fmt.Errorf("failed to resolve constraints: %w", err)
+		}
+
+		t.Constraints = &constraints
 	} else if opMode == "localjobs" {
 		if t.Constraints == nil {
 			return fmt.Errorf("constraints are required")
@@ -221,7 +241,7 @@ func (t *ServerBackupCreate) Validate(state jobstate.State) error {
 	count, _ := concurrentBackupState.LoadOrStore(state.GuildID(), 0)
 
 	if count >= t.Constraints.MaxServerBackups {
-		return fmt.Errorf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups)
+		return types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups), nil)
 	}
 
 	return nil
@@ -240,7 +260,7 @@ func (t *ServerBackupCreate) Exec(
 	count, _ := concurrentBackupState.LoadOrStore(guildId, 0)
 
 	if count >= t.Constraints.MaxServerBackups {
-		return nil, fmt.Errorf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups)
+		return nil, types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups), nil)
 	}
 
 	concurrentBackupState.Store(guildId, count+1)
@@ -254,6 +274,21 @@ func (t *ServerBackupCreate) Exec(
 		}
 	}()
 
+	// Backups are created in 4 steps: server settings, guild assets, messages and writing the backup
+	reportProgress := func(step string, stepIndex, itemsDone, itemsTotal int) {
+		err := progstate.ReportProgress(types.ProgressReport{
+			Step:       step,
+			StepIndex:  stepIndex,
+			StepsTotal: 4,
+			ItemsDone:  itemsDone,
+			ItemsTotal: itemsTotal,
+		})
+
+		if err != nil {
+			l.Warn("Failed to report progress", zap.Error(err))
+		}
+	}
+
 	t1 := time.Now()
 
 	var aeSource iblfile.AutoEncryptor
@@ -295,6 +330,7 @@ func (t *ServerBackupCreate) Exec(
 	}
 
 	l.Info("Backing up server settings")
+	reportProgress("backup_settings", 1, 0, 0)
 
 	// Fetch guild
 	g, err := discord.Guild(guildId, discordgo.WithContext(ctx))
@@ -367,7 +403,9 @@ func (t *ServerBackupCreate) Exec(
 	// Backup guild assets
 	l.Info("Backing up guild assets", zap.Strings("assets", t.Options.BackupGuildAssets))
 
-	for _, b := range t.Options.BackupGuildAssets {
+	for i, b := range t.Options.BackupGuildAssets {
+		reportProgress("backup_guild_assets", 2, i, len(t.Options.BackupGuildAssets))
+
 		switch b {
 		case "icon":
 			if g.Icon == "" {
@@ -404,6 +442,8 @@ func (t *ServerBackupCreate) Exec(
 		}
 	}
 
+	reportProgress("backup_messages", 3, 0, 0)
+
 	// Backup messages
 	if t.Options.BackupMessages {
 		perChannelBackupMap, err := common.CreateChannelAllocations(
@@ -430,6 +470,9 @@ func (t *ServerBackupCreate) Exec(
 			return nil, fmt.Errorf("error writing channel allocations: %w", err)
 		}
 
+		var messagesDone int
+		messagesTotal := perChannelBackupMap.TotalAllocations()
+
 		// Backup messages
 		err = common.ChannelAllocationStream(
 			perChannelBackupMap,
@@ -438,6 +481,9 @@ func (t *ServerBackupCreate) Exec(
 
 				msgs, err := backupChannelMessages(state, t.Constraints, l, f, channelID, allocation)
 
+				messagesDone += len(msgs)
+				reportProgress("backup_messages", 3, min(messagesDone, messagesTotal), messagesTotal)
+
 				// Write messages of this section regardless of error
 				if len(msgs) > 0 {
 					errMsg := writeMsgpack(f, "messages/"+channelID, msgs)
@@ -473,6 +519,8 @@ func (t *ServerBackupCreate) Exec(
 		}
 	}
 
+	reportProgress("write_backup", 4, 0, 0)
+
 	dbgInfo := state.DebugInfo()
 	metadata := iblfile.Meta{
 		CreatedAt: time.Now(),
@@ -1,6 +1,7 @@
 package backups
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -80,7 +81,7 @@ func readMsgpackSection[T any](f *iblfile.AutoEncryptedFile_FullFile, name strin
 	section, err := f.Get(name)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get section %s: %w", name, err)
+		return nil, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("failed to get section %s: %w", name, err))
 	}
 
 	dec := msgpack.NewDecoder(section)
@@ -92,12 +93,49 @@ func readMsgpackSection[T any](f *iblfile.AutoEncryptedFile_FullFile, name strin
 	err = dec.Decode(&outp)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode section %s: %w", name, err)
+		return nil, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("failed to decode section %s: %w", name, err))
 	}
 
 	return &outp, nil
 }
 
+// decryptTracker records whether decrypting a backup failed, telling a wrong password apart from a corrupted backup
+type decryptTracker struct {
+	iblfile.AutoEncryptor
+	failed bool
+}
+
+func (d *decryptTracker) Decrypt(b []byte) ([]byte, error) {
+	out, err := d.AutoEncryptor.Decrypt(b)
+
+	if err != nil {
+		d.failed = true
+	}
+
+	return out, err
+}
+
+// countMsgpackSection returns the length of a msgpack encoded array section without decoding its elements
+func countMsgpackSection(f *iblfile.AutoEncryptedFile_FullFile, name string) (int, error) {
+	section, err := f.Get(name)
+
+	if err != nil {
+		return 0, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("failed to get section %s: %w", name, err))
+	}
+
+	// Read through a separate reader so the section can still be fully decoded later on
+	dec := msgpack.NewDecoder(bytes.NewReader(section.Bytes()))
+
+	n, err := dec.DecodeArrayLen()
+
+	if err != nil {
+		return 0, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("failed to decode section %s: %w", name, err))
+	}
+
+	// A nil array has a length of -1
+	return max(n, 0), nil
+}
+
 func convertToDataUri(mimeType string, data []byte) string {
 	// Base64 encode
 	b64enc := base64.StdEncoding.EncodeToString(data)
@@ -136,6 +174,19 @@ func (t *ServerBackupRestore) Expiry() *time.Duration {
 	return nil
 }
 
+func (t *ServerBackupRestore) Timeout() time.Duration {
+	if t.Constraints == nil || t.Constraints.Restore == nil {
+		return 0
+	}
+
+	return time.Duration(t.Constraints.Restore.Timeout)
+}
+
+// A retried restore resumes from the last step it completed
+func (t *ServerBackupRestore) RetryPolicy() interfaces.RetryPolicy {
+	return interfaces.DefaultRetryPolicy
+}
+
 func (t *ServerBackupRestore) Resumable() bool {
 	return true
 }
@@ -143,8 +194,17 @@ func (t *ServerBackupRestore) Resumable() bool {
 // Validate validates the job and sets up state if needed
 func (t *ServerBackupRestore) Validate(state jobstate.State) error {
 	opMode := state.OperationMode()
-	if t.Constraints == nil || opMode == "jobs" {
-		t.Constraints = FreePlanBackupConstraints // TODO: Add other constraint types based on plans once we have them
+	if opMode == "jobs" {
+		var constraints BackupConstraints
+		err := state.ConstraintProvider().Resolve(state.Context(), state.GuildID(), ConstraintKind, FreePlanBackupConstraints, &constraints)
+
+		if err != nil {
+			return fmt.Errorf("failed to resolve constraints: %w", err)
+		}
+
+		t.Constraints = &constraints
+	} else if t.Constraints == nil {
+		t.Constraints = FreePlanBackupConstraints
 	}
 
 	if t.Options.BackupSource == "" {
@@ -180,7 +240,7 @@ func (t *ServerBackupRestore) Validate(state jobstate.State) error {
 	count, _ := concurrentBackupState.LoadOrStore(state.GuildID(), 0)
 
 	if count >= t.Constraints.MaxServerBackups {
-		return fmt.Errorf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups)
+		return types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups), nil)
 	}
 
 	return nil
@@ -199,7 +259,7 @@ func (t *ServerBackupRestore) Exec(
 	count, _ := concurrentBackupState.LoadOrStore(guildId, 0)
 
 	if count >= t.Constraints.MaxServerBackups {
-		return nil, fmt.Errorf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups)
+		return nil, types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("you already have more than %d backup-related jobs in progress, please wait for it to finish", t.Constraints.MaxServerBackups), nil)
 	}
 
 	concurrentBackupState.Store(guildId, count+1)
@@ -236,7 +296,7 @@ func (t *ServerBackupRestore) Exec(
 
 	// Limit body size to MaxBodySize
 	if resp.ContentLength > t.Constraints.Restore.MaxBodySize {
-		return nil, fmt.Errorf("backup too large, expected less than %d bytes, got %d bytes", t.Constraints.Restore.MaxBodySize, resp.ContentLength)
+		return nil, types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("backup too large, expected less than %d bytes, got %d bytes", t.Constraints.Restore.MaxBodySize, resp.ContentLength), nil)
 	}
 
 	resp.Body = http.MaxBytesReader(nil, resp.Body, t.Constraints.Restore.MaxBodySize)
@@ -249,13 +309,17 @@ func (t *ServerBackupRestore) Exec(
 	t1 := time.Now()
 
 	var aeSource iblfile.AutoEncryptor
+	var decryptor *decryptTracker
 
 	if t.Options.Decrypt == "" {
 		aeSource = noencryption.NoEncryptionSource{}
 	} else {
-		aeSource = aes256.AES256Source{
-			EncryptionKey: t.Options.Decrypt,
+		decryptor = &decryptTracker{
+			AutoEncryptor: aes256.AES256Source{
+				EncryptionKey: t.Options.Decrypt,
+			},
 		}
+		aeSource = decryptor
 	}
 
 	t.Options.Decrypt = "" // Clear encryption key
@@ -263,7 +327,11 @@ func (t *ServerBackupRestore) Exec(
 	f, err := iblfile.OpenAutoEncryptedFile_FullFile(resp.Body, aeSource)
 
 	if err != nil {
-		return nil, fmt.Errorf("error loading file: %w, is the password correct", err)
+		if decryptor != nil && decryptor.failed {
+			return nil, types.NewJobError(types.JobErrorCodeBadPassword, "failed to decrypt the backup, is the password correct?", fmt.Errorf("error loading file: %w", err))
+		}
+
+		return nil, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("error loading file: %w", err))
 	}
 
 	t2 := time.Now()
@@ -275,7 +343,7 @@ func (t *ServerBackupRestore) Exec(
 	sections, err := f.Sections()
 
 	if err != nil {
-		return nil, fmt.Errorf("error getting sections: %w", err)
+		return nil, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("error getting sections: %w", err))
 	}
 
 	keys := make([]string, 0, len(sections))
@@ -329,15 +397,15 @@ func (t *ServerBackupRestore) Exec(
 	basePerms := utils.BasePermissions(tgtGuild, m)
 
 	if !utils.CheckPermission(basePerms, discordgo.PermissionManageChannels) {
-		return nil, fmt.Errorf("bot does not have 'Manage Channels' permissions")
+		return nil, types.NewJobError(types.JobErrorCodeMissingPermissions, "bot does not have 'Manage Channels' permissions", nil)
 	}
 
 	if !utils.CheckPermission(basePerms, discordgo.PermissionManageRoles) {
-		return nil, fmt.Errorf("bot does not have 'Manage Roles' permissions")
+		return nil, types.NewJobError(types.JobErrorCodeMissingPermissions, "bot does not have 'Manage Roles' permissions", nil)
 	}
 
 	if !utils.CheckPermission(basePerms, discordgo.PermissionManageWebhooks) {
-		return nil, fmt.Errorf("bot does not have 'Manage Webhooks' permissions")
+		return nil, types.NewJobError(types.JobErrorCodeMissingPermissions, "bot does not have 'Manage Webhooks' permissions", nil)
 	}
 
 	// Get highest role
@@ -366,11 +434,11 @@ func (t *ServerBackupRestore) Exec(
 	}
 
 	if tgtBotGuildHighestRole == nil {
-		return nil, fmt.Errorf("bot does not have any roles")
+		return nil, types.NewJobError(types.JobErrorCodeMissingPermissions, "bot does not have any roles", nil)
 	}
 
 	if tgtBotGuildHighestRole.Position <= 0 {
-		return nil, fmt.Errorf("bot role isnt high enough")
+		return nil, types.NewJobError(types.JobErrorCodeMissingPermissions, "bot role isn't high enough", nil)
 	}
 
 	// Fetch channels of guild
@@ -392,7 +460,7 @@ func (t *ServerBackupRestore) Exec(
 	}
 
 	if srcGuild.ID == "" {
-		return nil, fmt.Errorf("guild data is invalid [id is empty], likely an internal decoding error")
+		return nil, types.NewJobError(types.JobErrorCodeInvalidBackup, "the backup is invalid or corrupted", fmt.Errorf("guild data is invalid [id is empty], likely an internal decoding error"))
 	}
 
 	var srcIsCommunity = slices.Contains(srcGuild.Features, discordgo.GuildFeatureCommunity)
@@ -1020,6 +1088,45 @@ func (t *ServerBackupRestore) Exec(
 
 					restoredChannelsMap := prevState.RestoredChannelsMap
 
+					// Count the messages to restore upfront, only the array header of each section is decoded
+					var itemsTotal = totalMessages
+					for backedUpChannelId, restoredChannelId := range restoredChannelsMap {
+						if _, ok := sections["messages/"+backedUpChannelId]; !ok {
+							continue
+						}
+
+						if _, ok := prevState.DoneChannels[restoredChannelId]; ok {
+							continue
+						}
+
+						count, err := countMsgpackSection(f, "messages/"+backedUpChannelId)
+
+						if err != nil {
+							if t.Options.IgnoreRestoreErrors {
+								continue
+							}
+							return nil, nil, fmt.Errorf("failed to count messages: %w", err)
+						}
+
+						itemsTotal += count
+					}
+
+					itemsTotal = min(itemsTotal, t.Constraints.Restore.TotalMaxMessages)
+					itemsDone := totalMessages
+
+					reportProgress := func() {
+						err := progstate.ReportProgress(types.ProgressReport{
+							ItemsDone:  min(itemsDone, itemsTotal),
+							ItemsTotal: itemsTotal,
+						})
+
+						if err != nil {
+							l.Warn("Failed to report progress", zap.Error(err))
+						}
+					}
+
+					reportProgress()
+
 					var currentChannelMap = make(map[string]*discordgo.Channel) // Map of current channel id to channel object
 					for _, channel := range tgtGuild.Channels {
 						currentChannelMap[channel.ID] = channel
@@ -1070,7 +1177,7 @@ func (t *ServerBackupRestore) Exec(
 
 						// Now send the messages, reversing the order due to how Get Channel Messages works
 						for i := len(bm) - 1; i >= 0; i-- {
-							if totalMessages > t.Constraints.Create.TotalMaxMessages {
+							if totalMessages > t.Constraints.Restore.TotalMaxMessages {
 								l.Warn("Hit total max messages limit, stopping", zap.Int("totalMessages", totalMessages))
 								break
 							}
@@ -1080,6 +1187,10 @@ func (t *ServerBackupRestore) Exec(
 								continue
 							}
 
+							// Messages that are skipped or fail to send count as done too
+							itemsDone++
+							reportProgress()
+
 							var rm = discordgo.WebhookParams{
 								Content:         bm[i].Message.Content,
 								Username:        bm[i].Message.Author.Username,
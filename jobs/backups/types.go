@@ -9,31 +9,38 @@ import (
 )
 
 type BackupCreateConstraints struct {
-	TotalMaxMessages          int // The maximum number of messages to backup
-	MinPerChannel             int // The minimum number of messages per channel
-	DefaultPerChannel         int // The default number of messages per channel
-	JpegReencodeQuality       int // The quality to use when reencoding to JPEGs
-	GuildAssetReencodeQuality int // The quality to use when reencoding guild assets
+	TotalMaxMessages          int            `json:"total_max_messages"`           // The maximum number of messages to backup
+	MinPerChannel             int            `json:"min_per_channel"`              // The minimum number of messages per channel
+	DefaultPerChannel         int            `json:"default_per_channel"`          // The default number of messages per channel
+	JpegReencodeQuality       int            `json:"jpeg_reencode_quality"`        // The quality to use when reencoding to JPEGs
+	GuildAssetReencodeQuality int            `json:"guild_asset_reencode_quality"` // The quality to use when reencoding guild assets
+	Timeout                   timex.Duration `json:"timeout"`                      // How long creating a backup may take
 }
 
 type BackupRestoreConstraints struct {
-	RoleDeleteSleep    timex.Duration // How long to sleep between role deletes
-	RoleCreateSleep    timex.Duration // How long to sleep between role creates
-	ChannelDeleteSleep timex.Duration // How long to sleep between channel deletes
-	ChannelCreateSleep timex.Duration // How long to sleep between channel creates
-	ChannelEditSleep   timex.Duration // How long to sleep between channel edits
-	SendMessageSleep   timex.Duration // How long to sleep between message sends
-	HttpClientTimeout  timex.Duration // How long to wait for HTTP requests to complete
-	MaxBodySize        int64          // The maximum size of the backup file to download/use
+	RoleDeleteSleep    timex.Duration `json:"role_delete_sleep"`    // How long to sleep between role deletes
+	RoleCreateSleep    timex.Duration `json:"role_create_sleep"`    // How long to sleep between role creates
+	ChannelDeleteSleep timex.Duration `json:"channel_delete_sleep"` // How long to sleep between channel deletes
+	ChannelCreateSleep timex.Duration `json:"channel_create_sleep"` // How long to sleep between channel creates
+	ChannelEditSleep   timex.Duration `json:"channel_edit_sleep"`   // How long to sleep between channel edits
+	SendMessageSleep   timex.Duration `json:"send_message_sleep"`   // How long to sleep between message sends
+	HttpClientTimeout  timex.Duration `json:"http_client_timeout"`  // How long to wait for HTTP requests to complete
+	MaxBodySize        int64          `json:"max_body_size"`        // The maximum size of the backup file to download/use
+	TotalMaxMessages   int            `json:"total_max_messages"`   // The maximum number of messages to restore
+	Timeout            timex.Duration `json:"timeout"`              // How long restoring a backup may take, reset every time the restore is resumed
 }
 
 type BackupConstraints struct {
-	Create           *BackupCreateConstraints
-	Restore          *BackupRestoreConstraints
-	MaxServerBackups int    // How many backup/restore jobs can run concurrently per server
-	FileType         string // The file type to use for backups
+	Create           *BackupCreateConstraints  `json:"create"`
+	Restore          *BackupRestoreConstraints `json:"restore"`
+	MaxServerBackups int                       `json:"max_server_backups"` // How many backup/restore jobs can run concurrently per server
+	FileType         string                    `json:"file_type"`          // The file type to use for backups
 }
 
+// ConstraintKind is the kind of constraints backup jobs resolve through the constraint provider
+const ConstraintKind = "backups"
+
+// FreePlanBackupConstraints are the default constraints of backup jobs, plans and overrides are merged on top of these
 var FreePlanBackupConstraints = &BackupConstraints{
 	Create: &BackupCreateConstraints{
 		TotalMaxMessages:          1000,
@@ -41,6 +48,7 @@ var FreePlanBackupConstraints = &BackupConstraints{
 		DefaultPerChannel:         100,
 		JpegReencodeQuality:       75,
 		GuildAssetReencodeQuality: 85,
+		Timeout:                   30 * timex.Minute,
 	},
 	Restore: &BackupRestoreConstraints{
 		RoleDeleteSleep:    1 * timex.Second,
@@ -51,6 +59,8 @@ var FreePlanBackupConstraints = &BackupConstraints{
 		SendMessageSleep:   350 * timex.Millisecond,
 		HttpClientTimeout:  10 * timex.Second,
 		MaxBodySize:        250_000_000, // 100MB
+		TotalMaxMessages:   1000,
+		Timeout:            2 * timex.Hour,
 	},
 	MaxServerBackups: 1,
 	FileType:         "backup.server",
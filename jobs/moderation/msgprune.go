@@ -45,6 +45,18 @@ func (t *MessagePrune) Expiry() *time.Duration {
 	return nil
 }
 
+func (t *MessagePrune) Timeout() time.Duration {
+	if t.Constraints == nil || t.Constraints.MessagePrune == nil {
+		return 0
+	}
+
+	return time.Duration(t.Constraints.MessagePrune.Timeout)
+}
+
+func (t *MessagePrune) RetryPolicy() interfaces.RetryPolicy {
+	return interfaces.DefaultRetryPolicy
+}
+
 func (t *MessagePrune) Resumable() bool {
 	return true
 }
@@ -52,7 +64,14 @@ func (t *MessagePrune) Resumable() bool {
 func (t *MessagePrune) Validate(state jobstate.State) error {
 	opMode := state.OperationMode()
 	if opMode == "jobs" {
-		t.Constraints = FreePlanModerationConstraints // TODO: Add other constraint types based on plans once we have them
+		var constraints ModerationConstraints
+		err := state.ConstraintProvider().Resolve(state.Context(), state.GuildID(), ConstraintKind, FreePlanModerationConstraints, &constraints)
+
+		if err != nil {
+			return fmt.Errorf("failed to resolve constraints: %w", err)
+		}
+
+		t.Constraints = &constraints
 	} else if opMode == "localjobs" {
 		if t.Constraints == nil {
 			return fmt.Errorf("constraints are required")
@@ -89,7 +108,7 @@ func (t *MessagePrune) Validate(state jobstate.State) error {
 	count, _ := concurrentModerationState.LoadOrStore(state.GuildID(), 0)
 
 	if count >= t.Constraints.MaxServerModeration {
-		return fmt.Errorf("you already have more than %d moderation jobs in progress, please wait for it to finish", t.Constraints.MaxServerModeration)
+		return types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("you already have more than %d moderation jobs in progress, please wait for it to finish", t.Constraints.MaxServerModeration), nil)
 	}
 
 	return nil
@@ -108,7 +127,7 @@ func (t *MessagePrune) Exec(
 	count, _ := concurrentModerationState.LoadOrStore(guildId, 0)
 
 	if count >= t.Constraints.MaxServerModeration {
-		return nil, fmt.Errorf("you already have more than %d moderation jobs in progress, please wait for it to finish", t.Constraints.MaxServerModeration)
+		return nil, types.NewJobError(types.JobErrorCodeQuotaExceeded, fmt.Sprintf("you already have more than %d moderation jobs in progress, please wait for it to finish", t.Constraints.MaxServerModeration), nil)
 	}
 
 	concurrentModerationState.Store(guildId, count+1)
@@ -161,7 +180,7 @@ func (t *MessagePrune) Exec(
 	basePerms := utils.BasePermissions(g, m)
 
 	if basePerms&discordgo.PermissionManageMessages != discordgo.PermissionManageMessages && basePerms&discordgo.PermissionAdministrator != discordgo.PermissionAdministrator {
-		return nil, fmt.Errorf("bot does not have 'Manage Messages' permissions")
+		return nil, types.NewJobError(types.JobErrorCodeMissingPermissions, "bot does not have 'Manage Messages' permissions", nil)
 	}
 
 	perChannelBackupMap, err := common.CreateChannelAllocations(
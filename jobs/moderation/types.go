@@ -15,19 +15,25 @@ type MessagePruneOpts struct {
 }
 
 type MessagePruneConstraints struct {
-	TotalMaxMessages int `description:"The maximum number of messages to prune"`
-	MinPerChannel    int `description:"The minimum number of messages to prune per channel"`
+	TotalMaxMessages int            `json:"total_max_messages" description:"The maximum number of messages to prune"`
+	MinPerChannel    int            `json:"min_per_channel" description:"The minimum number of messages to prune per channel"`
+	Timeout          timex.Duration `json:"timeout" description:"How long pruning messages may take"`
 }
 
 type ModerationConstraints struct {
-	MessagePrune        *MessagePruneConstraints
-	MaxServerModeration int // How many moderation related jobs can run concurrently per server
+	MessagePrune        *MessagePruneConstraints `json:"message_prune"`
+	MaxServerModeration int                      `json:"max_server_moderation"` // How many moderation related jobs can run concurrently per server
 }
 
+// ConstraintKind is the kind of constraints moderation jobs resolve through the constraint provider
+const ConstraintKind = "moderation"
+
+// FreePlanModerationConstraints are the default constraints of moderation jobs, plans and overrides are merged on top of these
 var FreePlanModerationConstraints = &ModerationConstraints{
 	MessagePrune: &MessagePruneConstraints{
 		TotalMaxMessages: 1000,
 		MinPerChannel:    10,
+		Timeout:          15 * timex.Minute,
 	},
 	MaxServerModeration: 5,
 }
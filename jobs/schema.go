@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/Anti-Raid/jobserver/interfaces"
+	"github.com/Anti-Raid/jobserver/utils"
+)
+
+// All configurable options of a job live in its Options field
+const optionsField = "Options"
+
+// optionsType returns the type of the options of a job, if it has any
+func optionsType(jobImpl interfaces.JobImpl) (reflect.Type, bool) {
+	t := reflect.TypeOf(jobImpl)
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	f, ok := t.FieldByName(optionsField)
+
+	if !ok {
+		return nil, false
+	}
+
+	return f.Type, true
+}
+
+// OptionsSchema returns a JSON schema of the data that can be used to spawn a job
+func OptionsSchema(jobImpl interfaces.JobImpl) map[string]any {
+	properties := map[string]any{}
+
+	if t, ok := optionsType(jobImpl); ok {
+		optsSchema := utils.JsonSchema(t)
+
+		// Secret options are never returned back
+		_, secret := OptionFieldSafety(jobImpl)
+		optsProperties, _ := optsSchema["properties"].(map[string]any)
+
+		for _, name := range secret {
+			if prop, ok := optsProperties[strings.TrimPrefix(name, optionsField+".")].(map[string]any); ok {
+				prop["writeOnly"] = true
+			}
+		}
+
+		properties[optionsField] = optsSchema
+	}
+
+	return map[string]any{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      jobImpl.Name(),
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// probeValue returns a non-zero value of a type
+func probeValue(t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString("probe")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Slice:
+		v.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), probeValue(t.Elem())))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(t))
+		v.SetMapIndex(probeValue(t.Key()), probeValue(t.Elem()))
+	case reflect.Pointer:
+		p := reflect.New(t.Elem())
+		p.Elem().Set(probeValue(t.Elem()))
+		v.Set(p)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				v.Field(i).Set(probeValue(t.Field(i).Type))
+			}
+		}
+	}
+
+	return v
+}
+
+// OptionFieldSafety splits the options of a job into those that are kept when the job is stored
+// through Fields() and those that are secret and omitted from storage
+//
+// This works by calling Fields() on a copy of the job with every option set and checking which options survived
+func OptionFieldSafety(jobImpl interfaces.JobImpl) (fieldsSafe []string, secret []string) {
+	t, ok := optionsType(jobImpl)
+
+	if !ok || reflect.TypeOf(jobImpl).Kind() != reflect.Pointer {
+		return nil, nil
+	}
+
+	fieldsSafe, secret = []string{}, []string{}
+
+	probeJob := reflect.New(reflect.TypeOf(jobImpl).Elem())
+	probeOpts := probeValue(t)
+	probeJob.Elem().FieldByName(optionsField).Set(probeOpts)
+
+	stored := reflect.ValueOf(probeJob.Interface().(interfaces.JobImpl).Fields()[optionsField])
+
+	for _, f := range reflect.VisibleFields(t) {
+		name := utils.JsonFieldName(f)
+
+		if name == "" || f.Anonymous {
+			continue
+		}
+
+		if stored.IsValid() && stored.Type() == t && reflect.DeepEqual(stored.FieldByIndex(f.Index).Interface(), probeOpts.FieldByIndex(f.Index).Interface()) {
+			fieldsSafe = append(fieldsSafe, optionsField+"."+name)
+		} else {
+			secret = append(secret, optionsField+"."+name)
+		}
+	}
+
+	return fieldsSafe, secret
+}
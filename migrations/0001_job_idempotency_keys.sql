@@ -0,0 +1,4 @@
+-- Idempotency keys for job spawns, a repeated spawn with the same key and guild returns the existing job
+ALTER TABLE jobs ADD COLUMN idempotency_key TEXT;
+
+CREATE INDEX jobs_guild_id_idempotency_key_idx ON jobs (guild_id, idempotency_key, created_at) WHERE idempotency_key IS NOT NULL;
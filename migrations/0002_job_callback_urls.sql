@@ -0,0 +1,2 @@
+-- URL that is sent a signed POST request once a job reaches a terminal state
+ALTER TABLE jobs ADD COLUMN callback_url TEXT;
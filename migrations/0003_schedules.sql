@@ -0,0 +1,16 @@
+-- Schedules spawn a job on a cron expression
+CREATE TABLE schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    guild_id TEXT NOT NULL,
+    name TEXT NOT NULL, -- Name of the job to spawn
+    data JSONB NOT NULL, -- Data the job is spawned with
+    cron TEXT NOT NULL,
+    missed_run_policy TEXT NOT NULL DEFAULT 'run_once', -- What to do with runs missed while the jobserver was down (run_once/skip)
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_job_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX schedules_guild_id_idx ON schedules (guild_id, created_at);
+CREATE INDEX schedules_next_run_at_idx ON schedules (next_run_at);
@@ -0,0 +1,4 @@
+-- Jobs spawned with a run_at are stored as 'scheduled' until they are due
+ALTER TABLE jobs ADD COLUMN run_at TIMESTAMPTZ;
+
+CREATE INDEX jobs_scheduled_run_at_idx ON jobs (run_at) WHERE state = 'scheduled';
@@ -0,0 +1,2 @@
+-- The attempt a job is on, incremented every time a failed job is retried
+ALTER TABLE jobs ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Jobs spawned with a dependency are stored as 'waiting' until the job they depend on finishes
+ALTER TABLE jobs ADD COLUMN depends_on TEXT;
+
+CREATE INDEX jobs_waiting_depends_on_idx ON jobs (depends_on) WHERE state = 'waiting';
@@ -0,0 +1,6 @@
+-- Jobs are leased by the jobserver node executing them, jobs whose lease has expired are orphaned and may be
+-- resumed by any node
+ALTER TABLE jobs ADD COLUMN lease_owner TEXT;
+ALTER TABLE jobs ADD COLUMN lease_expires_at TIMESTAMPTZ;
+
+CREATE INDEX jobs_lease_expires_at_idx ON jobs (lease_expires_at);
@@ -0,0 +1,2 @@
+-- A structured report of how far along a job is, see types.ProgressReport
+ALTER TABLE jobs ADD COLUMN progress JSONB;
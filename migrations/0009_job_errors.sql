@@ -0,0 +1,2 @@
+-- Why a job failed as a machine-readable code and user-safe message, see types.JobError
+ALTER TABLE jobs ADD COLUMN error JSONB;
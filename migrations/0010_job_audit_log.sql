@@ -0,0 +1,25 @@
+-- Who spawned a job, see types.Initiator
+ALTER TABLE jobs ADD COLUMN initiator JSONB;
+
+-- Append-only trail of actions taken on jobs, kept after the job itself is deleted
+CREATE TABLE job_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    job_id TEXT NOT NULL,
+    guild_id TEXT NOT NULL,
+    event TEXT NOT NULL, -- spawn/cancel/output_download/delete
+    actor JSONB NOT NULL, -- The types.Initiator that took the action
+    data JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX job_audit_log_job_id_idx ON job_audit_log (job_id, created_at);
+CREATE INDEX job_audit_log_guild_id_idx ON job_audit_log (guild_id, created_at);
+
+CREATE FUNCTION job_audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'job_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER job_audit_log_append_only BEFORE UPDATE OR DELETE ON job_audit_log
+    FOR EACH ROW EXECUTE FUNCTION job_audit_log_append_only();
@@ -0,0 +1,36 @@
+-- Plans decide the constraints (limits) jobs of a guild run under, see state.ConstraintProvider
+--
+-- Constraints are JSON objects keyed by the snake_case json names of the constraint structs of the kind
+-- (backups.BackupConstraints/moderation.ModerationConstraints), nested objects are merged key by key so only
+-- changed constraints need to be set. Durations are strings such as "1h30m" (or nanoseconds) and unknown keys
+-- are rejected, e.g. for backups: {"max_server_backups": 2, "create": {"total_max_messages": 5000}}
+CREATE TABLE plans (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The constraints of a plan per kind of job (backups/moderation), merged on top of the defaults
+CREATE TABLE plan_constraints (
+    plan_id TEXT NOT NULL REFERENCES plans (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    constraints JSONB NOT NULL,
+    PRIMARY KEY (plan_id, kind)
+);
+
+-- The plan a guild is entitled to, guilds without a (current) plan use the defaults
+CREATE TABLE guild_plans (
+    guild_id TEXT PRIMARY KEY,
+    plan_id TEXT NOT NULL REFERENCES plans (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Per guild constraints merged on top of those of its plan
+CREATE TABLE guild_constraint_overrides (
+    guild_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    constraints JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (guild_id, kind)
+);
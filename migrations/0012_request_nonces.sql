@@ -0,0 +1,8 @@
+-- Nonces of accepted signed requests, shared by all jobserver nodes so a request cannot be replayed against another
+-- node (or after a restart). Rows can be deleted once expires_at, the time the signature itself expires, has passed
+CREATE TABLE request_nonces (
+    nonce TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX request_nonces_expires_at_idx ON request_nonces (expires_at);
@@ -97,6 +97,24 @@ func (o *ObjectStorage) ensureBucketExists(ctx context.Context, bucketName strin
 	return nil
 }
 
+// The bucket checked by Ping
+var PingBucket = "antiraid.jobserver"
+
+// Ping checks that the object storage is reachable and usable with the configured credentials
+func (o *ObjectStorage) Ping(ctx context.Context) error {
+	switch o.c.Type {
+	case "local":
+		_, err := os.Stat(o.c.BasePath)
+		return err
+	case "s3-like":
+		// The bucket does not need to exist, checking it only needs access to the bucket itself unlike listing all buckets
+		_, err := o.minio.BucketExists(ctx, o.c.BasePath+PingBucket)
+		return err
+	default:
+		return fmt.Errorf("operation not supported for object storage type %s", o.c.Type)
+	}
+}
+
 // Saves a file to the object storage
 //
 // Note that 'expiry' is not supported for local storage
@@ -222,7 +240,7 @@ func (o *ObjectStorage) Delete(ctx context.Context, bucketName, dir, filename st
 		return os.Remove(filepath.Join(o.c.BasePath, bucketName, dir, filename))
 	case "s3-like":
 		if filename == "" {
-			return o.minio.RemoveObject(ctx, o.c.BasePath+bucketName, dir, minio.RemoveObjectOptions{})
+			return o.deletePrefix(ctx, o.c.BasePath+bucketName, strings.TrimSuffix(dir, "/")+"/")
 		}
 
 		return o.minio.RemoveObject(ctx, o.c.BasePath+bucketName, dir+"/"+filename, minio.RemoveObjectOptions{})
@@ -231,6 +249,52 @@ func (o *ObjectStorage) Delete(ctx context.Context, bucketName, dir, filename st
 	}
 }
 
+// deletePrefix deletes all objects under a prefix of a s3-like bucket, s3 has no directories to delete
+func (o *ObjectStorage) deletePrefix(ctx context.Context, bucketName, prefix string) error {
+	// Cancelling on return stops both the lister below and minio's own goroutines if we bail out early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objectsCh := make(chan minio.ObjectInfo)
+	listErrCh := make(chan error, 1)
+
+	go func() {
+		defer close(objectsCh)
+
+		for obj := range o.minio.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if obj.Err != nil {
+				listErrCh <- obj.Err
+				return
+			}
+
+			select {
+			case objectsCh <- obj:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// The errors channel must be drained fully, it is only closed once RemoveObjects is done with objectsCh
+	var removeErr error
+	for rErr := range o.minio.RemoveObjects(ctx, bucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+		if removeErr == nil {
+			removeErr = fmt.Errorf("failed to remove %s: %w", rErr.ObjectName, rErr.Err)
+		}
+	}
+
+	// Stop the lister in case RemoveObjects returned without consuming everything
+	cancel()
+
+	select {
+	case err := <-listErrCh:
+		return err
+	default:
+	}
+
+	return removeErr
+}
+
 // Returns the name of the bucket for the given guild
 func GuildBucket(guildId string) string {
 	return "antiraid.guild." + guildId
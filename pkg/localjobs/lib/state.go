@@ -7,6 +7,7 @@ import (
 
 	state "github.com/Anti-Raid/jobserver/pkg/server/state"
 	jobstate "github.com/Anti-Raid/jobserver/state"
+	"github.com/Anti-Raid/jobserver/types"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -46,6 +47,14 @@ func (ts State) GuildID() string {
 	return ts.GuildId
 }
 
+func (State) Interrupted() bool {
+	return false
+}
+
+func (State) ConstraintProvider() jobstate.ConstraintProvider {
+	return jobstate.DefaultConstraintProvider{}
+}
+
 type Progress struct{}
 
 func (ts Progress) GetProgress() (*jobstate.Progress, error) {
@@ -58,3 +67,7 @@ func (ts Progress) GetProgress() (*jobstate.Progress, error) {
 func (ts Progress) SetProgress(prog *jobstate.Progress) error {
 	return nil
 }
+
+func (ts Progress) ReportProgress(report types.ProgressReport) error {
+	return nil
+}
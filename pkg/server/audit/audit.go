@@ -0,0 +1,52 @@
+// Package audit records an append-only trail of the actions taken on jobs
+package audit
+
+import (
+	"context"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/Anti-Raid/jobserver/types"
+	"go.uber.org/zap"
+)
+
+const (
+	EventSpawn          = "spawn"
+	EventCancel         = "cancel"
+	EventOutputDownload = "output_download"
+	EventDelete         = "delete"
+)
+
+// Entry is a single action taken on a job
+type Entry struct {
+	Event   string
+	JobID   string
+	GuildID string
+	Actor   types.Initiator
+
+	// Extra details of the action, if any
+	Data map[string]any
+}
+
+// Log appends an entry to the audit log
+func Log(ctx context.Context, e Entry) error {
+	_, err := state.Pool.Exec(
+		ctx,
+		"INSERT INTO job_audit_log (job_id, guild_id, event, actor, data) VALUES ($1, $2, $3, $4, $5)",
+		e.JobID,
+		e.GuildID,
+		e.Event,
+		e.Actor,
+		e.Data,
+	)
+
+	return err
+}
+
+// LogOrWarn appends an entry to the audit log, only logging a failure as the action has already been taken
+func LogOrWarn(ctx context.Context, e Entry) {
+	err := Log(ctx, e)
+
+	if err != nil {
+		state.Logger.Error("Failed to write audit log entry", zap.String("event", e.Event), zap.String("job_id", e.JobID), zap.Error(err))
+	}
+}
@@ -0,0 +1,110 @@
+// Package constraints resolves the constraints of jobs from the plan of a guild along with its overrides
+package constraints
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/anti-raid/eureka/jsonimpl"
+)
+
+// PostgresProvider resolves constraints from the plan_constraints and guild_constraint_overrides tables
+//
+// The constraints of the current plan of the guild are merged on top of the defaults, followed by the overrides of
+// the guild. Nested objects are merged key by key, so a plan only needs to set the constraints it changes
+type PostgresProvider struct{}
+
+func (PostgresProvider) Resolve(ctx context.Context, guildId, kind string, defaults, out any) error {
+	var plan, override map[string]any
+
+	err := state.Pool.QueryRow(
+		ctx,
+		`SELECT
+			(SELECT pc.constraints FROM guild_plans gp JOIN plan_constraints pc ON pc.plan_id = gp.plan_id
+			WHERE gp.guild_id = $1 AND pc.kind = $2 AND (gp.expires_at IS NULL OR gp.expires_at > NOW())),
+			(SELECT constraints FROM guild_constraint_overrides WHERE guild_id = $1 AND kind = $2)`,
+		guildId,
+		kind,
+	).Scan(&plan, &override)
+
+	if err != nil {
+		return fmt.Errorf("failed to fetch constraints: %w", err)
+	}
+
+	return resolve(guildId, defaults, plan, override, out)
+}
+
+// resolve merges the plan constraints and then the overrides on top of defaults, unmarshalling the result into out
+func resolve(guildId string, defaults any, plan, override map[string]any, out any) error {
+	b, err := jsonimpl.Marshal(defaults)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal default constraints: %w", err)
+	}
+
+	var merged map[string]any
+
+	err = jsonimpl.Unmarshal(b, &merged)
+
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal default constraints: %w", err)
+	}
+
+	err = merge(merged, plan, "")
+
+	if err != nil {
+		return fmt.Errorf("invalid plan constraints for guild %s: %w", guildId, err)
+	}
+
+	err = merge(merged, override, "")
+
+	if err != nil {
+		return fmt.Errorf("invalid constraint overrides for guild %s: %w", guildId, err)
+	}
+
+	b, err = jsonimpl.Marshal(merged)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal constraints: %w", err)
+	}
+
+	err = jsonimpl.Unmarshal(b, out)
+
+	if err != nil {
+		return fmt.Errorf("invalid constraints for guild %s: %w", guildId, err)
+	}
+
+	return nil
+}
+
+// merge merges src into dst, recursing into objects present in both
+//
+// Keys not present in dst (the defaults) are rejected, as they would otherwise be silently ignored when unmarshalling
+func merge(dst, src map[string]any, path string) error {
+	for k, v := range src {
+		dstV, ok := dst[k]
+
+		if !ok {
+			return fmt.Errorf("unknown constraint %s%s", path, k)
+		}
+
+		srcObj, ok := v.(map[string]any)
+
+		if ok {
+			if dstObj, ok := dstV.(map[string]any); ok {
+				err := merge(dstObj, srcObj, path+k+".")
+
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		dst[k] = v
+	}
+
+	return nil
+}
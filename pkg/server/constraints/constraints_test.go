@@ -0,0 +1,185 @@
+package constraints
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	defaults := func() map[string]any {
+		return map[string]any{
+			"max_server_backups": 1.0,
+			"file_type":          "iblfile",
+			"create": map[string]any{
+				"total_max_messages": 500.0,
+				"min_per_channel":    50.0,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		src     map[string]any
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name: "nil override",
+			src:  nil,
+			want: defaults(),
+		},
+		{
+			name: "empty override",
+			src:  map[string]any{},
+			want: defaults(),
+		},
+		{
+			name: "top level override",
+			src:  map[string]any{"max_server_backups": 3.0},
+			want: map[string]any{
+				"max_server_backups": 3.0,
+				"file_type":          "iblfile",
+				"create": map[string]any{
+					"total_max_messages": 500.0,
+					"min_per_channel":    50.0,
+				},
+			},
+		},
+		{
+			name: "nested override keeps sibling keys",
+			src:  map[string]any{"create": map[string]any{"total_max_messages": 1000.0}},
+			want: map[string]any{
+				"max_server_backups": 1.0,
+				"file_type":          "iblfile",
+				"create": map[string]any{
+					"total_max_messages": 1000.0,
+					"min_per_channel":    50.0,
+				},
+			},
+		},
+		{
+			name:    "unknown top level key",
+			src:     map[string]any{"max_server_backup": 3.0},
+			wantErr: "unknown constraint max_server_backup",
+		},
+		{
+			name:    "unknown nested key",
+			src:     map[string]any{"create": map[string]any{"totalMaxMessages": 1000.0}},
+			wantErr: "unknown constraint create.totalMaxMessages",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := defaults()
+			err := merge(dst, tt.src, "")
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("merge error = %v, want %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("merge: unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(dst, tt.want) {
+				t.Errorf("merge = %v, want %v", dst, tt.want)
+			}
+		})
+	}
+}
+
+type testCreateConstraints struct {
+	TotalMaxMessages int `json:"total_max_messages"`
+	MinPerChannel    int `json:"min_per_channel"`
+}
+
+type testConstraints struct {
+	Create           *testCreateConstraints `json:"create"`
+	MaxServerBackups int                    `json:"max_server_backups"`
+}
+
+func TestResolve(t *testing.T) {
+	defaults := &testConstraints{
+		Create:           &testCreateConstraints{TotalMaxMessages: 500, MinPerChannel: 50},
+		MaxServerBackups: 1,
+	}
+
+	tests := []struct {
+		name     string
+		plan     map[string]any
+		override map[string]any
+		want     testConstraints
+		wantErr  string
+	}{
+		{
+			name: "no plan or override",
+			want: testConstraints{Create: &testCreateConstraints{TotalMaxMessages: 500, MinPerChannel: 50}, MaxServerBackups: 1},
+		},
+		{
+			name: "plan only",
+			plan: map[string]any{"create": map[string]any{"total_max_messages": 1000.0}},
+			want: testConstraints{Create: &testCreateConstraints{TotalMaxMessages: 1000, MinPerChannel: 50}, MaxServerBackups: 1},
+		},
+		{
+			name:     "override wins over plan",
+			plan:     map[string]any{"create": map[string]any{"total_max_messages": 1000.0}, "max_server_backups": 2.0},
+			override: map[string]any{"create": map[string]any{"total_max_messages": 5000.0}},
+			want:     testConstraints{Create: &testCreateConstraints{TotalMaxMessages: 5000, MinPerChannel: 50}, MaxServerBackups: 2},
+		},
+		{
+			name:     "empty override",
+			plan:     map[string]any{"max_server_backups": 2.0},
+			override: map[string]any{},
+			want:     testConstraints{Create: &testCreateConstraints{TotalMaxMessages: 500, MinPerChannel: 50}, MaxServerBackups: 2},
+		},
+		{
+			name:    "unknown plan key",
+			plan:    map[string]any{"create": map[string]any{"max_messages": 1000.0}},
+			wantErr: "invalid plan constraints",
+		},
+		{
+			name:     "unknown override key",
+			override: map[string]any{"maxServerBackups": 2.0},
+			wantErr:  "invalid constraint overrides",
+		},
+		{
+			name:     "wrong type",
+			override: map[string]any{"max_server_backups": "many"},
+			wantErr:  "invalid constraints",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testConstraints
+			err := resolve("1234", defaults, tt.plan, tt.override, &got)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolve error = %v, want it to contain %q", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("resolve: unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolve = %+v (create %+v), want %+v (create %+v)", got, got.Create, tt.want, tt.want.Create)
+			}
+		})
+	}
+
+	// Resolving must not modify the defaults
+	if defaults.Create.TotalMaxMessages != 500 || defaults.MaxServerBackups != 1 {
+		t.Errorf("defaults were modified: %+v (create %+v)", defaults, defaults.Create)
+	}
+}
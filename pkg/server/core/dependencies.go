@@ -0,0 +1,288 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	jobs "github.com/Anti-Raid/jobserver/jobs"
+	"github.com/Anti-Raid/jobserver/pkg/server/jobrunner"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// String options of a waiting job equal to this are replaced with the output URL of the job it depends on
+const UpstreamOutputPlaceholder = "job://upstream"
+
+// Maximum number of waiting jobs promoted per call to PromoteWaitingJobs
+var PromoteBatchSize = 50
+
+// SpawnPipeline spawns an ordered pipeline of jobs, each job waiting on the previous one to complete
+//
+// All jobs are validated before any of them are created and are created in a single transaction, so either all
+// of them are created or none are. Only the first job is queued, once the transaction has committed
+func SpawnPipeline(pipeline rpc_messages.SpawnPipeline) (*rpc_messages.SpawnPipelineResponse, error) {
+	if len(pipeline.Jobs) == 0 {
+		return nil, fmt.Errorf("pipeline must have at least one job")
+	}
+
+	for i, spawn := range pipeline.Jobs {
+		if spawn.DependsOn != "" || spawn.RunAt != nil {
+			return nil, fmt.Errorf("job %d of pipeline cannot set depends_on or run_at", i)
+		}
+
+		spawn.ValidateOnly = true
+		resp, err := Spawn(spawn)
+
+		if err != nil {
+			return nil, fmt.Errorf("job %d of pipeline is invalid: %w", i, err)
+		}
+
+		if resp == nil || resp.Validation == nil || !resp.Validation.Valid {
+			var verr string
+			if resp != nil && resp.Validation != nil {
+				verr = resp.Validation.Error
+			}
+
+			return nil, fmt.Errorf("job %d of pipeline failed to validate: %s", i, verr)
+		}
+	}
+
+	tx, err := state.Pool.Begin(state.Context)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	//nolint:errcheck
+	defer tx.Rollback(state.Context)
+
+	ids := make([]string, 0, len(pipeline.Jobs))
+	stages := make([]*spawned, 0, len(pipeline.Jobs))
+
+	for i, spawn := range pipeline.Jobs {
+		spawn.Create = true
+		spawn.Execute = true
+
+		if i > 0 {
+			spawn.DependsOn = ids[i-1]
+		}
+
+		s, err := prepareSpawn(tx, spawn)
+
+		if err != nil {
+			return nil, fmt.Errorf("error spawning job %d of pipeline: %w", i, err)
+		}
+
+		ids = append(ids, s.resp.ID)
+		stages = append(stages, s)
+	}
+
+	err = tx.Commit(state.Context)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for i, s := range stages {
+		err = s.finish()
+
+		if err != nil {
+			// A job left pending is failed by orphan recovery, which fails the jobs waiting on it as well
+			return nil, fmt.Errorf("error spawning job %d of pipeline: %w", i, err)
+		}
+	}
+
+	return &rpc_messages.SpawnPipelineResponse{
+		IDs: ids,
+	}, nil
+}
+
+// upstreamOutputUrl returns the URL a waiting job in guildId can fetch the output of its upstream job with
+func upstreamOutputUrl(guildId string, upstream *types.Job) (string, error) {
+	if upstream.Output == nil || upstream.Output.Filename == "" {
+		return "", fmt.Errorf("job %s has no output", upstream.ID)
+	}
+
+	// Spawn only allows depending on jobs of the same guild, the job:// transport only has access to its bucket anyways
+	if upstream.GuildID != guildId {
+		return "", fmt.Errorf("job %s belongs to another guild", upstream.ID)
+	}
+
+	return "job:///" + jobs.GetPathFromOutput(upstream.ID) + "/" + upstream.Output.Filename, nil
+}
+
+// replacePlaceholder replaces all string values equal to UpstreamOutputPlaceholder with url
+func replacePlaceholder(v any, url string) any {
+	switch v := v.(type) {
+	case string:
+		if v == UpstreamOutputPlaceholder {
+			return url
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = replacePlaceholder(e, url)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = replacePlaceholder(e, url)
+		}
+	}
+
+	return v
+}
+
+type waitingJob struct {
+	ID          string         `db:"id"`
+	GuildID     string         `db:"guild_id"`
+	DependsOn   string         `db:"depends_on"`
+	InitialOpts map[string]any `db:"initial_opts"`
+}
+
+// PromoteWaitingJobs queues all waiting jobs whose upstream job has completed, failing those whose upstream job
+// did not complete
+func PromoteWaitingJobs(ctx context.Context) error {
+	if !Accepting() {
+		return nil
+	}
+
+	tx, err := state.Pool.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	//nolint:errcheck
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT j.id, j.guild_id, j.depends_on, o.initial_opts FROM jobs j
+		JOIN ongoing_jobs o ON o.id = j.id
+		LEFT JOIN jobs u ON u.id::text = j.depends_on
+		WHERE j.state = 'waiting' AND (u.id IS NULL OR u.state = ANY($1))
+		LIMIT $2 FOR UPDATE OF j SKIP LOCKED`,
+		finishedStates,
+		PromoteBatchSize,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	waiting, err := pgx.CollectRows(rows, pgx.RowToStructByName[waitingJob])
+
+	if err != nil {
+		return err
+	}
+
+	var promoted []string
+
+	for _, w := range waiting {
+		url, err := waitingJobUpstreamUrl(ctx, w)
+
+		if err != nil {
+			state.Logger.Info("Failing job as its upstream job did not complete", zap.String("id", w.ID), zap.String("depends_on", w.DependsOn), zap.Error(err))
+
+			_, err = tx.Exec(ctx, "UPDATE jobs SET state = 'failed' WHERE id = $1", w.ID)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, "DELETE FROM ongoing_jobs WHERE id = $1", w.ID)
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE ongoing_jobs SET initial_opts = $1 WHERE id = $2", replacePlaceholder(w.InitialOpts, url), w.ID)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE jobs SET state = 'queued' WHERE id = $1", w.ID)
+
+		if err != nil {
+			return err
+		}
+
+		promoted = append(promoted, w.ID)
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range promoted {
+		err = ResumeJob(ctx, id)
+
+		if errors.Is(err, jobrunner.ErrQueueStopped) {
+			// Draining started midway, the remaining jobs are queued without a lease so they are recovered elsewhere
+			return nil
+		}
+
+		if err != nil {
+			state.Logger.Error("Failed to queue job after its upstream job completed", zap.String("id", id), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// waitingJobUpstreamUrl returns the output URL of the upstream job of a waiting job, erroring if it did not complete
+func waitingJobUpstreamUrl(ctx context.Context, w waitingJob) (string, error) {
+	upstream, err := GetJob(ctx, w.DependsOn)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch upstream job: %w", err)
+	}
+
+	if upstream.State != "completed" {
+		return "", fmt.Errorf("upstream job %s", upstream.State)
+	}
+
+	// Jobs without output can still be depended on as long as nothing references their output
+	url, err := upstreamOutputUrl(w.GuildID, upstream)
+
+	if err != nil {
+		if !referencesUpstream(w.InitialOpts) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return url, nil
+}
+
+// referencesUpstream returns whether or not any string value is UpstreamOutputPlaceholder
+func referencesUpstream(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == UpstreamOutputPlaceholder
+	case map[string]any:
+		for _, e := range v {
+			if referencesUpstream(e) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if referencesUpstream(e) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
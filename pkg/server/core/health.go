@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// How long a single dependency check may take before it is considered unhealthy
+var HealthCheckTimeout = 5 * time.Second
+
+// healthChecks are the dependencies of the jobserver along with how to check them
+var healthChecks = map[string]func(ctx context.Context) error{
+	"postgres": func(ctx context.Context) error {
+		return state.Pool.Ping(ctx)
+	},
+	"object_storage": func(ctx context.Context) error {
+		return state.ObjectStorage.Ping(ctx)
+	},
+	"discord": func(ctx context.Context) error {
+		_, err := state.Discord.User("@me", discordgo.WithContext(ctx))
+		return err
+	},
+}
+
+// Accepting returns whether or not the jobserver is accepting new jobs
+func Accepting() bool {
+	return !draining.Load()
+}
+
+// How long the result of a health check is reused for, keeps readiness probes from hammering dependencies
+var HealthCacheTTL = 5 * time.Second
+
+var healthCache struct {
+	sync.Mutex
+	resp      *rpc_messages.HealthResponse
+	checkedAt time.Time
+}
+
+// CheckHealth returns the health of all dependencies of the jobserver, reusing the last result for up to HealthCacheTTL
+func CheckHealth() *rpc_messages.HealthResponse {
+	// Holding the lock while checking means concurrent probes wait on one check instead of starting their own
+	healthCache.Lock()
+	defer healthCache.Unlock()
+
+	if healthCache.resp == nil || time.Since(healthCache.checkedAt) > HealthCacheTTL {
+		// The checks are not tied to any one request so a probe disconnecting does not poison the cache
+		healthCache.resp = checkHealth(state.Context)
+		healthCache.checkedAt = time.Now()
+	}
+
+	resp := *healthCache.resp
+	resp.Accepting = Accepting()
+
+	return &resp
+}
+
+// checkHealth checks all dependencies of the jobserver concurrently
+func checkHealth(ctx context.Context) *rpc_messages.HealthResponse {
+	resp := &rpc_messages.HealthResponse{
+		Healthy:      true,
+		Dependencies: make(map[string]rpc_messages.DependencyHealth, len(healthChecks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+
+			dh := rpc_messages.DependencyHealth{
+				Status: "up",
+			}
+
+			if err != nil {
+				dh.Status = "down"
+				state.Logger.Warn("Health check failed", zap.String("dependency", name), zap.Duration("latency", time.Since(start)), zap.Error(err))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			resp.Dependencies[name] = dh
+			resp.Healthy = resp.Healthy && err == nil
+		}()
+	}
+
+	wg.Wait()
+
+	return resp
+}
@@ -0,0 +1,428 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	jobs "github.com/Anti-Raid/jobserver/jobs"
+	"github.com/Anti-Raid/jobserver/objectstorage"
+	"github.com/Anti-Raid/jobserver/pkg/server/audit"
+	"github.com/Anti-Raid/jobserver/pkg/server/jobrunner"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/Anti-Raid/jobserver/utils"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// How long the URL returned by GetOutputUrl is valid for
+var OutputUrlExpiry = 15 * time.Minute
+
+var (
+	DefaultStatusesLimit = 100
+	MaxStatusesLimit     = 1000
+	DefaultJobListLimit  = 50
+	MaxJobListLimit      = 250
+)
+
+var (
+	partialJobCols    = utils.GetCols(types.PartialJob{})
+	partialJobColsStr = strings.Join(partialJobCols, ", ")
+)
+
+var (
+	// ErrJobFinished is returned when trying to act on a job that has already finished
+	ErrJobFinished = errors.New("job has already finished")
+
+	// ErrNoOutput is returned when trying to download the output of a job that has none
+	ErrNoOutput = errors.New("job has no output")
+
+	// ErrJobNotPausable is returned when trying to pause a job that is not running or cannot be resumed
+	ErrJobNotPausable = errors.New("only running resumable jobs can be paused")
+
+	// ErrJobNotPaused is returned when trying to resume a job that is not paused
+	ErrJobNotPaused = errors.New("job is not paused")
+
+	// ErrInvalidCursor is returned when a job listing cursor is malformed
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// finishedStates are the states a job will never leave
+var finishedStates = []string{"completed", "failed", "cancelled", "timed_out"}
+
+// FinishedStates returns the states a job will never leave
+func FinishedStates() []string {
+	return slices.Clone(finishedStates)
+}
+
+// IsFinished returns whether or not a job state is final
+func IsFinished(jobState string) bool {
+	return slices.Contains(finishedStates, jobState)
+}
+
+// GetJob fetches a job by its ID
+//
+// Returns pgx.ErrNoRows if the job does not exist
+func GetJob(ctx context.Context, id string) (*types.Job, error) {
+	return getJob(ctx, state.Pool, id)
+}
+
+// querier is either the pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func getJob(ctx context.Context, db querier, id string) (*types.Job, error) {
+	row, err := db.Query(ctx, "SELECT "+jobColsStr+" FROM jobs WHERE id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer row.Close()
+
+	return pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[types.Job])
+}
+
+// GetJobState returns the current state of a job
+//
+// Returns pgx.ErrNoRows if the job does not exist
+func GetJobState(ctx context.Context, id string) (string, error) {
+	var jobState string
+	err := state.Pool.QueryRow(ctx, "SELECT state FROM jobs WHERE id = $1", id).Scan(&jobState)
+	return jobState, err
+}
+
+// GetJobStatuses returns a page of the statuses of a job along with the total number of statuses
+//
+// Returns pgx.ErrNoRows if the job does not exist
+func GetJobStatuses(ctx context.Context, id string, offset, limit int) ([]map[string]any, int, error) {
+	var statuses []map[string]any
+	var total int
+
+	// Postgres arrays are 1-indexed and slices are inclusive on both ends
+	err := state.Pool.QueryRow(
+		ctx,
+		"SELECT COALESCE(statuses[$2:$3], '{}'), COALESCE(cardinality(statuses), 0) FROM jobs WHERE id = $1",
+		id,
+		offset+1,
+		offset+limit,
+	).Scan(&statuses, &total)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return statuses, total, nil
+}
+
+// CancelJob cancels a job
+//
+// Jobs executing in this process have their context cancelled and are then marked as cancelled by the job runner.
+// Jobs that are not executing (e.g. created without being executed) are marked as cancelled directly
+func CancelJob(ctx context.Context, id string, actor types.Initiator) error {
+	var guildId string
+	err := state.Pool.QueryRow(ctx, "SELECT guild_id FROM jobs WHERE id = $1", id).Scan(&guildId)
+
+	if err != nil {
+		return err
+	}
+
+	err = cancelJob(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	audit.LogOrWarn(ctx, audit.Entry{
+		Event:   audit.EventCancel,
+		JobID:   id,
+		GuildID: guildId,
+		Actor:   actor,
+	})
+
+	return nil
+}
+
+func cancelJob(ctx context.Context, id string) error {
+	if jobrunner.Cancel(id) {
+		return nil
+	}
+
+	tx, err := state.Pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	//nolint:errcheck
+	defer tx.Rollback(ctx)
+
+	var jobState string
+	err = tx.QueryRow(ctx, "SELECT state FROM jobs WHERE id = $1 FOR UPDATE", id).Scan(&jobState)
+
+	if err != nil {
+		return err
+	}
+
+	if IsFinished(jobState) {
+		return ErrJobFinished
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE jobs SET state = $1 WHERE id = $2", "cancelled", id)
+
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM ongoing_jobs WHERE id = $1", id)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete job from ongoing_jobs: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetOutputUrl returns a temporary URL to download the output of a job with, recording the download in the audit log
+//
+// Returns ErrNoOutput if the job has no output (yet)
+func GetOutputUrl(ctx context.Context, id string, actor types.Initiator) (*url.URL, error) {
+	job, err := GetJob(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Output == nil || job.Output.Filename == "" {
+		return nil, ErrNoOutput
+	}
+
+	u, err := state.ObjectStorage.GetUrl(ctx, objectstorage.GuildBucket(job.GuildID), jobs.GetPathFromOutput(job.ID), job.Output.Filename, OutputUrlExpiry, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get output url: %w", err)
+	}
+
+	// Downloads that cannot be audited are refused
+	err = audit.Log(ctx, audit.Entry{
+		Event:   audit.EventOutputDownload,
+		JobID:   job.ID,
+		GuildID: job.GuildID,
+		Actor:   actor,
+		Data: map[string]any{
+			"filename": job.Output.Filename,
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to write audit log entry: %w", err)
+	}
+
+	return u, nil
+}
+
+// PauseJob asks a running resumable job to stop at its next step boundary, keeping its progress in ongoing_jobs
+//
+// The job is marked as pausing until the node executing it has stopped it, after which it is marked as paused
+func PauseJob(ctx context.Context, id string) error {
+	tx, err := state.Pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	//nolint:errcheck
+	defer tx.Rollback(ctx)
+
+	var jobState string
+	var resumable bool
+	err = tx.QueryRow(ctx, "SELECT state, resumable FROM jobs WHERE id = $1 FOR UPDATE", id).Scan(&jobState, &resumable)
+
+	if err != nil {
+		return err
+	}
+
+	if IsFinished(jobState) {
+		return ErrJobFinished
+	}
+
+	if jobState == "pausing" || jobState == "paused" {
+		return nil
+	}
+
+	if jobState != "running" || !resumable {
+		return ErrJobNotPausable
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE jobs SET state = $1 WHERE id = $2", "pausing", id)
+
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	// Jobs executing on another node are paused by that node on its next lease renewal
+	jobrunner.Pause(id)
+	return nil
+}
+
+// ResumePausedJob queues a paused job again, continuing from its persisted progress
+func ResumePausedJob(ctx context.Context, id string) error {
+	// Claiming moves the job out of the paused state, so concurrent resumes only queue it once
+	tag, err := state.Pool.Exec(ctx, "UPDATE jobs SET state = $1 WHERE id = $2 AND state = $3", "queued", id, "paused")
+
+	if err != nil {
+		return fmt.Errorf("failed to update job: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		jobState, err := GetJobState(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		if IsFinished(jobState) {
+			return ErrJobFinished
+		}
+
+		return ErrJobNotPaused
+	}
+
+	err = ResumeJob(ctx, id)
+
+	if err != nil {
+		// Leave the job paused so resuming it can be tried again
+		_, err2 := state.Pool.Exec(ctx, "UPDATE jobs SET state = $1 WHERE id = $2 AND state = $3", "paused", id, "queued")
+
+		if err2 != nil {
+			state.Logger.Error("Failed to mark job as paused again", zap.String("id", id), zap.Error(err2))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// JobListFilter filters the jobs returned by ListGuildJobs
+type JobListFilter struct {
+	Name          string
+	State         string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	// The cursor returned by a previous call to ListGuildJobs, if any
+	Cursor string
+	Limit  int
+}
+
+// encodeJobCursor encodes the position of a job in a job listing
+func encodeJobCursor(j types.PartialJob) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(j.CreatedAt.Format(time.RFC3339Nano) + "|" + j.ID))
+}
+
+// decodeJobCursor decodes a cursor created by encodeJobCursor
+func decodeJobCursor(cursor string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	createdAtStr, id, ok := strings.Cut(string(b), "|")
+
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return createdAt, id, nil
+}
+
+// ListGuildJobs lists the jobs of a guild, newest first
+func ListGuildJobs(ctx context.Context, guildId string, f JobListFilter) (*types.JobListResponse, error) {
+	var conds = []string{"guild_id = $1"}
+	var args = []any{guildId}
+
+	addCond := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "$?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if f.Name != "" {
+		addCond("name = $?", f.Name)
+	}
+
+	if f.State != "" {
+		addCond("state = $?", f.State)
+	}
+
+	if f.CreatedAfter != nil {
+		addCond("created_at >= $?", *f.CreatedAfter)
+	}
+
+	if f.CreatedBefore != nil {
+		addCond("created_at < $?", *f.CreatedBefore)
+	}
+
+	if f.Cursor != "" {
+		createdAt, id, err := decodeJobCursor(f.Cursor)
+
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, createdAt, id)
+		conds = append(conds, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	// Fetch one extra job to know if there is a next page
+	args = append(args, f.Limit+1)
+
+	rows, err := state.Pool.Query(
+		ctx,
+		"SELECT "+partialJobColsStr+" FROM jobs WHERE "+strings.Join(conds, " AND ")+" ORDER BY created_at DESC, id DESC LIMIT $"+strconv.Itoa(len(args)),
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	jobList, err := pgx.CollectRows(rows, pgx.RowToStructByName[types.PartialJob])
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &types.JobListResponse{
+		Jobs: jobList,
+	}
+
+	if len(jobList) > f.Limit {
+		resp.Jobs = jobList[:f.Limit]
+		cursor := encodeJobCursor(resp.Jobs[len(resp.Jobs)-1])
+		resp.NextCursor = &cursor
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/types"
+)
+
+func TestJobCursorRoundTrip(t *testing.T) {
+	tests := []types.PartialJob{
+		{ID: "0b9c5c9e-3c4b-4a5e-9a51-6f0d6d1f0a2b", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)},
+		{ID: "whole-second", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ID: "other-zone", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6000, time.FixedZone("UTC+5", 5*60*60))},
+		{ID: "id|with|separators", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+
+	for _, j := range tests {
+		createdAt, id, err := decodeJobCursor(encodeJobCursor(j))
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", j.ID, err)
+			continue
+		}
+
+		if id != j.ID || !createdAt.Equal(j.CreatedAt) {
+			t.Errorf("%s: decoded (%s, %s), want (%s, %s)", j.ID, createdAt, id, j.CreatedAt, j.ID)
+		}
+	}
+}
+
+func TestDecodeJobCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not base64!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("2024-01-02T03:04:05Z|a"))},
+		{"missing separator", encode("2024-01-02T03:04:05Z")},
+		{"missing id", encode("2024-01-02T03:04:05Z|")},
+		{"missing time", encode("|a")},
+		{"invalid time", encode("yesterday|a")},
+		{"unix time", encode("1704164645|a")},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := decodeJobCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, ErrInvalidCursor)
+		}
+	}
+}
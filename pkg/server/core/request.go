@@ -4,10 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Anti-Raid/jobserver/interfaces"
 	jobs "github.com/Anti-Raid/jobserver/jobs"
+	"github.com/Anti-Raid/jobserver/pkg/server/audit"
 	"github.com/Anti-Raid/jobserver/pkg/server/jobrunner"
 	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
 	"github.com/Anti-Raid/jobserver/pkg/server/state"
@@ -21,12 +26,79 @@ import (
 var DefaultTimeout = 30 * time.Minute
 var ResumeOngoingJobTimeoutSecs = 15 * 60
 var DefaultValidationTimeout = 5 * time.Second
+var DefaultIdempotencyWindow = 24 * time.Hour
 
 var (
 	jobCols    = utils.GetCols(types.Job{})
 	jobColsStr = strings.Join(jobCols, ", ")
 )
 
+// ErrDraining is returned when spawning a job while the jobserver is shutting down
+var ErrDraining = errors.New("jobserver is shutting down, not accepting new jobs")
+
+// draining is set once the jobserver stops accepting new jobs
+var draining atomic.Bool
+
+// stopping is closed along with draining being set, for loops waiting on something to stop early
+var (
+	stopping     = make(chan struct{})
+	stoppingOnce sync.Once
+)
+
+// StopSpawning makes all future spawns that would execute a job fail with ErrDraining
+func StopSpawning() {
+	draining.Store(true)
+	stoppingOnce.Do(func() { close(stopping) })
+}
+
+// Stopping returns a channel that is closed once the jobserver stops accepting new jobs
+func Stopping() <-chan struct{} {
+	return stopping
+}
+
+func idempotencyWindow() time.Duration {
+	if state.Config.Jobserver.IdempotencyWindowSecs <= 0 {
+		return DefaultIdempotencyWindow
+	}
+
+	return time.Duration(state.Config.Jobserver.IdempotencyWindowSecs) * time.Second
+}
+
+// newJob creates a new instance of a job on the registry, filling it in with data
+func newJob(name string, data map[string]any) (interfaces.JobImpl, error) {
+	baseJobImpl, ok := jobs.JobImplRegistry[name]
+
+	if !ok {
+		return nil, fmt.Errorf("job %s does not exist on registry", name)
+	}
+
+	b, err := jsonimpl.Marshal(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling args: %w", err)
+	}
+
+	// Allocate a new job instead of unmarshalling into the (shared) registry entry
+	job := reflect.New(reflect.TypeOf(baseJobImpl).Elem()).Interface().(interfaces.JobImpl)
+
+	err = jsonimpl.Unmarshal(b, job)
+
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling args: %w", err)
+	}
+
+	return job, nil
+}
+
+// jobTimeout returns how long a single execution of a job may take
+func jobTimeout(job interfaces.JobImpl) time.Duration {
+	if tj, ok := job.(interfaces.TimeoutJobImpl); ok && tj.Timeout() > 0 {
+		return tj.Timeout()
+	}
+
+	return DefaultTimeout
+}
+
 func Spawn(spawn rpc_messages.Spawn) (*rpc_messages.SpawnResponse, error) {
 	defer func() {
 		if rvr := recover(); rvr != nil {
@@ -34,8 +106,59 @@ func Spawn(spawn rpc_messages.Spawn) (*rpc_messages.SpawnResponse, error) {
 		}
 	}()
 
-	if !spawn.Create && !spawn.Execute {
-		return nil, fmt.Errorf("either create or execute must be set")
+	s, err := prepareSpawn(state.Pool, spawn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.finish()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.resp, nil
+}
+
+// spawnDB is the pool or a transaction jobs are spawned in
+type spawnDB interface {
+	jobrunner.TxBeginner
+	querier
+}
+
+// A job created by prepareSpawn, its audit entry is only written and it is only queued by finish
+type spawned struct {
+	resp  *rpc_messages.SpawnResponse
+	audit *audit.Entry
+	queue *jobrunner.QueuedJob
+}
+
+// finish logs and queues a spawned job, when spawning in a transaction this must only be called once it has committed
+func (s *spawned) finish() error {
+	if s.audit != nil {
+		audit.LogOrWarn(state.Context, *s.audit)
+	}
+
+	if s.queue != nil {
+		err := jobrunner.Enqueue(*s.queue)
+
+		if err != nil {
+			return fmt.Errorf("error queueing job: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// prepareSpawn validates and creates a job in db without queueing it
+func prepareSpawn(db spawnDB, spawn rpc_messages.Spawn) (*spawned, error) {
+	if !spawn.Create && !spawn.Execute && !spawn.ValidateOnly {
+		return nil, fmt.Errorf("either create, execute or validate_only must be set")
+	}
+
+	if spawn.Execute && draining.Load() {
+		return nil, ErrDraining
 	}
 
 	if spawn.Name == "" {
@@ -46,28 +169,40 @@ func Spawn(spawn rpc_messages.Spawn) (*rpc_messages.SpawnResponse, error) {
 		return nil, fmt.Errorf("invalid guild id provided")
 	}
 
-	baseJobImpl, ok := jobs.JobImplRegistry[spawn.Name]
-
-	if !ok {
-		return nil, fmt.Errorf("job %s does not exist on registry", spawn.Name)
-	}
-
 	if len(spawn.Data) == 0 {
 		return nil, fmt.Errorf("invalid job data provided")
 	}
 
-	b, err := jsonimpl.Marshal(spawn.Data)
+	if spawn.RunAt != nil && (!spawn.Create || !spawn.Execute) {
+		return nil, fmt.Errorf("run_at requires both create and execute to be set")
+	}
 
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling args: %w", err)
+	if spawn.DependsOn != "" {
+		if !spawn.Create || !spawn.Execute {
+			return nil, fmt.Errorf("depends_on requires both create and execute to be set")
+		}
+
+		if spawn.RunAt != nil {
+			return nil, fmt.Errorf("depends_on and run_at cannot both be set")
+		}
 	}
 
-	job := baseJobImpl // Copy job
+	if spawn.Initiator.Source == "" {
+		spawn.Initiator.Source = types.InitiatorSourceAPI
+	}
+
+	if spawn.CallbackURL != "" {
+		err := jobrunner.ValidateCallbackURL(spawn.CallbackURL)
 
-	err = jsonimpl.Unmarshal(b, &job)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	job, err := newJob(spawn.Name, spawn.Data)
 
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling args: %w", err)
+		return nil, err
 	}
 
 	// Validate
@@ -78,20 +213,83 @@ func Spawn(spawn rpc_messages.Spawn) (*rpc_messages.SpawnResponse, error) {
 		Ctx:     ctx,
 	})
 
+	if spawn.ValidateOnly {
+		validation := &rpc_messages.SpawnValidation{
+			Valid: err == nil,
+			Data:  job.Fields(),
+		}
+
+		if err != nil {
+			validation.Error = err.Error()
+		}
+
+		return &spawned{
+			resp: &rpc_messages.SpawnResponse{
+				Validation: validation,
+			},
+		}, nil
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate job: %w", err)
 	}
 
+	if spawn.DependsOn != "" {
+		upstream, err := getJob(state.Context, db, spawn.DependsOn)
+
+		// Jobs of other guilds are reported as missing so their IDs cannot be probed
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && upstream.GuildID != spawn.GuildID) {
+			return nil, fmt.Errorf("depends_on job does not exist")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error fetching depends_on job: %w", err)
+		}
+
+		if IsFinished(upstream.State) && upstream.State != "completed" {
+			return nil, fmt.Errorf("depends_on job has already %s", upstream.State)
+		}
+	}
+
+	// Jobs due already are executed right away
+	var runAt *time.Time
+	if spawn.RunAt != nil && spawn.RunAt.After(time.Now()) {
+		runAt = spawn.RunAt
+	}
+
+	s := &spawned{}
+
 	// Create
 	var id string
+	var existing bool
 	if spawn.Create {
-		tid, err := jobrunner.Create(state.Context, state.Pool, job, spawn.GuildID)
+		tid, ex, err := jobrunner.Create(state.Context, db, job, spawn.GuildID, jobrunner.CreateOpts{
+			IdempotencyKey:    spawn.IdempotencyKey,
+			IdempotencyWindow: idempotencyWindow(),
+			CallbackURL:       spawn.CallbackURL,
+			RunAt:             runAt,
+			DependsOn:         spawn.DependsOn,
+			Initiator:         &spawn.Initiator,
+		})
 
 		if err != nil {
 			return nil, fmt.Errorf("error creating job: %w", err)
 		}
 
 		id = *tid
+		existing = ex
+
+		if !existing {
+			s.audit = &audit.Entry{
+				Event:   audit.EventSpawn,
+				JobID:   id,
+				GuildID: spawn.GuildID,
+				Actor:   spawn.Initiator,
+				Data: map[string]any{
+					"name": spawn.Name,
+				},
+			}
+		}
 	} else {
 		if spawn.ID == "" {
 			return nil, fmt.Errorf("id must be set if spawn.Create is false")
@@ -100,128 +298,228 @@ func Spawn(spawn rpc_messages.Spawn) (*rpc_messages.SpawnResponse, error) {
 		id = spawn.ID
 	}
 
-	// Execute
-	if spawn.Execute {
-		ctx, cancel := context.WithTimeout(state.Context, DefaultTimeout)
-		go jobrunner.Execute(ctx, cancel, id, job, nil, spawn.GuildID)
+	// Execute, unless the job was already spawned before
+	// Scheduled and waiting jobs are executed by the scheduler once due
+	if spawn.Execute && !existing && runAt == nil && spawn.DependsOn == "" {
+		s.queue = &jobrunner.QueuedJob{
+			ID:       id,
+			Job:      job,
+			GuildID:  spawn.GuildID,
+			Priority: spawn.Priority,
+			Timeout:  jobTimeout(job),
+		}
+	}
+
+	s.resp = &rpc_messages.SpawnResponse{
+		ID:       id,
+		Existing: existing,
 	}
 
-	return &rpc_messages.SpawnResponse{
-		ID: id,
-	}, nil
+	return s, nil
 }
 
+// Resume recovers all orphaned jobs on boot
 func Resume() {
-	state.Logger.Info("Deleting ancient ongoing jobs older than ResumeOngoingJobTimeoutSecs", zap.Int("timeout", ResumeOngoingJobTimeoutSecs))
+	state.Logger.Info("Looking for jobs to resume")
 
-	_, err := state.Pool.Exec(state.Context, "DELETE FROM ongoing_jobs WHERE created_at < NOW() - make_interval(secs => $1)", ResumeOngoingJobTimeoutSecs)
+	err := RecoverOrphanedJobs(state.Context)
 
 	if err != nil {
-		state.Logger.Error("Failed to delete ancient ongoing_jobs", zap.Error(err))
-		panic("Failed to delete ancient ongoing_jobs")
+		state.Logger.Error("Failed to recover orphaned jobs", zap.Error(err))
+		panic("Failed to recover orphaned jobs")
 	}
+}
 
-	state.Logger.Info("Looking for jobs to resume")
+// RecoverOrphanedJobs resumes (or fails) all jobs whose lease has expired, meaning the node executing them is gone
+//
+// This is safe to call from multiple nodes at once
+func RecoverOrphanedJobs(ctx context.Context) error {
+	// A draining node would only lease jobs it can no longer execute
+	if !Accepting() {
+		return nil
+	}
+
+	// Pending jobs are only executed through a spawn, fail those that were never executed
+	_, err := state.Pool.Exec(
+		ctx,
+		"UPDATE jobs SET state = 'failed' WHERE state = 'pending' AND created_at < NOW() - make_interval(secs => $1) AND (lease_expires_at IS NULL OR lease_expires_at < NOW())",
+		ResumeOngoingJobTimeoutSecs,
+	)
 
-	rows, err := state.Pool.Query(state.Context, "SELECT id, data, initial_opts, guild_id, created_at FROM ongoing_jobs")
+	if err != nil {
+		return fmt.Errorf("failed to fail orphaned pending jobs: %w", err)
+	}
+
+	// Jobs being paused by a node that went away are stopped, so they count as paused
+	_, err = state.Pool.Exec(
+		ctx,
+		"UPDATE jobs SET state = 'paused' WHERE state = 'pausing' AND (lease_expires_at IS NULL OR lease_expires_at < NOW())",
+	)
 
 	if err != nil {
-		state.Logger.Error("Failed to query ongoing_jobs", zap.Error(err))
-		panic("Failed to query ongoing_jobs")
+		return fmt.Errorf("failed to mark orphaned pausing jobs as paused: %w", err)
 	}
 
-	defer rows.Close()
+	// Jobs interrupted by a shutdown, paused, still queued, scheduled for later or waiting on another job are always kept
+	_, err = state.Pool.Exec(
+		ctx,
+		"DELETE FROM ongoing_jobs WHERE created_at < NOW() - make_interval(secs => $1) AND id NOT IN (SELECT id FROM jobs WHERE state IN ('interrupted', 'paused', 'queued', 'scheduled', 'waiting') OR lease_expires_at > NOW())",
+		ResumeOngoingJobTimeoutSecs,
+	)
 
-	for rows.Next() {
-		var id string
-		var data map[string]any
-		var initialOpts map[string]any
-		var createdAt time.Time
-		var guildId string
+	if err != nil {
+		return fmt.Errorf("failed to delete ancient ongoing_jobs: %w", err)
+	}
 
-		err = rows.Scan(&id, &data, &initialOpts, &createdAt, &guildId)
+	tx, err := state.Pool.Begin(ctx)
 
-		if err != nil {
-			state.Logger.Error("Failed to scan jobs", zap.Error(err))
-			panic("Failed to scan jobs")
-		}
+	if err != nil {
+		return err
+	}
 
-		// Select the job from the job db
-		row, err := state.Pool.Query(state.Context, "SELECT "+jobColsStr+" FROM jobs WHERE id = $1", id)
+	//nolint:errcheck
+	defer tx.Rollback(ctx)
 
-		if errors.Is(err, pgx.ErrNoRows) {
-			state.Logger.Error("Job not found", zap.String("id", id))
-			continue
-		}
+	// Scheduled and waiting jobs are executed by the scheduler once due, paused jobs once resumed, finished jobs never
+	rows, err := tx.Query(
+		ctx,
+		`SELECT j.id FROM jobs j JOIN ongoing_jobs o ON o.id = j.id
+		WHERE j.state NOT IN ('pending', 'scheduled', 'waiting', 'paused') AND j.state <> ALL($1)
+		AND (j.lease_expires_at IS NULL OR j.lease_expires_at < NOW())
+		FOR UPDATE OF j SKIP LOCKED`,
+		finishedStates,
+	)
 
-		if err != nil {
-			state.Logger.Error("Failed to query job", zap.Error(err))
-			continue
-		}
+	if err != nil {
+		return fmt.Errorf("failed to query orphaned jobs: %w", err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+
+	if err != nil {
+		return fmt.Errorf("failed to scan orphaned jobs: %w", err)
+	}
 
-		defer row.Close()
+	if len(ids) == 0 {
+		return nil
+	}
 
-		t, err := pgx.CollectOneRow(row, pgx.RowToAddrOfStructByName[types.Job])
+	// Lease the jobs to this node so no other node recovers them at the same time
+	_, err = tx.Exec(
+		ctx,
+		"UPDATE jobs SET lease_owner = $1, lease_expires_at = NOW() + make_interval(secs => $2) WHERE id = ANY($3)",
+		jobrunner.NodeID,
+		jobrunner.LeaseDuration().Seconds(),
+		ids,
+	)
 
-		if errors.Is(err, pgx.ErrNoRows) {
-			state.Logger.Error("Job not found", zap.String("id", id))
-			continue
+	if err != nil {
+		return fmt.Errorf("failed to lease orphaned jobs: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		state.Logger.Info("Recovering orphaned job", zap.String("id", id))
+
+		err = ResumeJob(ctx, id)
+
+		if errors.Is(err, jobrunner.ErrQueueStopped) {
+			// Draining started midway, hand the remaining jobs back for another node (or the next boot) to recover
+			_, err = state.Pool.Exec(ctx, "UPDATE jobs SET lease_owner = NULL, lease_expires_at = NULL WHERE id = ANY($1) AND lease_owner = $2", ids[i:], jobrunner.NodeID)
+
+			if err != nil {
+				return fmt.Errorf("failed to release orphaned jobs: %w", err)
+			}
+
+			return nil
 		}
 
 		if err != nil {
-			state.Logger.Error("Failed to collect job", zap.Error(err))
-			continue
+			state.Logger.Error("Failed to resume job", zap.String("id", id), zap.Error(err))
 		}
+	}
 
-		if !t.Resumable {
-			continue
-		}
+	return nil
+}
 
-		if t.State == "completed" || t.State == "failed" {
-			continue
-		}
+// failJob marks a job that will never be executed as failed
+func failJob(ctx context.Context, id string) error {
+	_, err := state.Pool.Exec(ctx, "UPDATE jobs SET state = 'failed', lease_owner = NULL, lease_expires_at = NULL WHERE id = $1", id)
 
-		baseJobImpl, ok := jobs.JobImplRegistry[t.Name]
+	if err != nil {
+		return err
+	}
 
-		if !ok {
-			state.Logger.Error("Task not found in registry", zap.String("id", id))
-			continue
-		}
+	_, err = state.Pool.Exec(ctx, "DELETE FROM ongoing_jobs WHERE id = $1", id)
 
-		b, err := jsonimpl.Marshal(initialOpts)
+	return err
+}
 
-		if err != nil {
-			state.Logger.Error("Failed to marshal job create opts", zap.Error(err))
-			continue
-		}
+// ResumeJob queues a job on ongoing_jobs for execution from its initial options
+//
+// Jobs that already started executing are only resumed if they are resumable, otherwise they are failed
+func ResumeJob(ctx context.Context, id string) error {
+	var initialOpts map[string]any
+	var guildId string
 
-		job := baseJobImpl // Copy job
+	err := state.Pool.QueryRow(ctx, "SELECT initial_opts, guild_id FROM ongoing_jobs WHERE id = $1", id).Scan(&initialOpts, &guildId)
 
-		err = jsonimpl.Unmarshal(b, &job)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ongoing job: %w", err)
+	}
+
+	t, err := GetJob(ctx, id)
+
+	if err != nil {
+		return fmt.Errorf("failed to fetch job: %w", err)
+	}
+
+	// The ongoing_jobs row of a finished job is stale, remove it so the job is not picked up again
+	if IsFinished(t.State) {
+		_, err = state.Pool.Exec(ctx, "DELETE FROM ongoing_jobs WHERE id = $1", id)
 
 		if err != nil {
-			state.Logger.Error("Failed to unmarshal job create opts", zap.Error(err))
-			continue
+			return fmt.Errorf("failed to delete ongoing job: %w", err)
 		}
 
-		// Validate
-		ctx, cancel := context.WithTimeout(state.Context, DefaultValidationTimeout)
+		return nil
+	}
 
-		err = job.Validate(jobrunner.JobrunnerState{
-			GuildId: guildId,
-			Ctx:     ctx,
-		})
+	// Jobs that never started can always be executed from scratch
+	if !t.Resumable && t.State != "queued" && t.State != "scheduled" {
+		state.Logger.Info("Failing job that cannot be resumed", zap.String("id", id), zap.String("state", t.State))
+		return failJob(ctx, id)
+	}
 
-		cancel()
+	job, err := newJob(t.Name, initialOpts)
 
-		if err != nil {
-			state.Logger.Error("Failed to validate job", zap.Error(err))
-			continue
-		}
+	if err != nil {
+		return fmt.Errorf("failed to create job from job create opts: %w", err)
+	}
+
+	// Validate
+	vctx, cancel := context.WithTimeout(ctx, DefaultValidationTimeout)
+
+	err = job.Validate(jobrunner.JobrunnerState{
+		GuildId: guildId,
+		Ctx:     vctx,
+	})
 
-		// Execute job
-		ctx, cancel = context.WithTimeout(state.Context, DefaultTimeout)
+	cancel()
 
-		go jobrunner.Execute(ctx, cancel, id, job, nil, guildId)
+	if err != nil {
+		return fmt.Errorf("failed to validate job: %w", err)
 	}
+
+	return jobrunner.Enqueue(jobrunner.QueuedJob{
+		ID:      id,
+		Job:     job,
+		GuildID: guildId,
+		Timeout: jobTimeout(job),
+	})
 }
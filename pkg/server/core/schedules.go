@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/Anti-Raid/jobserver/utils"
+	"github.com/Anti-Raid/jobserver/utils/cron"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	scheduleCols    = utils.GetCols(types.Schedule{})
+	scheduleColsStr = strings.Join(scheduleCols, ", ")
+)
+
+const (
+	// Spawn a single job for all runs missed while the jobserver was down
+	MissedRunPolicyRunOnce = "run_once"
+
+	// Skip all runs missed while the jobserver was down
+	MissedRunPolicySkip = "skip"
+)
+
+// MaxSchedulesPerGuild is the maximum number of schedules a guild may have
+var MaxSchedulesPerGuild = 25
+
+var (
+	// ErrInvalidSchedule is returned when creating a schedule with an invalid cron expression, policy or job
+	ErrInvalidSchedule = errors.New("invalid schedule")
+
+	// ErrTooManySchedules is returned when a guild already has MaxSchedulesPerGuild schedules
+	ErrTooManySchedules = errors.New("guild has too many schedules")
+)
+
+// NextRun returns the next time a cron expression matches after t, in UTC
+func NextRun(expr string, t time.Time) (time.Time, error) {
+	sched, err := cron.Parse(expr)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := sched.Next(t.UTC())
+
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression never matches")
+	}
+
+	return next, nil
+}
+
+// CreateSchedule validates and creates a schedule for a guild
+func CreateSchedule(ctx context.Context, guildId string, cs rpc_messages.CreateSchedule) (*types.Schedule, error) {
+	if cs.MissedRunPolicy == "" {
+		cs.MissedRunPolicy = MissedRunPolicyRunOnce
+	}
+
+	if cs.MissedRunPolicy != MissedRunPolicyRunOnce && cs.MissedRunPolicy != MissedRunPolicySkip {
+		return nil, fmt.Errorf("%w: missed run policy must be one of %s, %s", ErrInvalidSchedule, MissedRunPolicyRunOnce, MissedRunPolicySkip)
+	}
+
+	nextRunAt, err := NextRun(cs.Cron, time.Now())
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cron expression: %s", ErrInvalidSchedule, err)
+	}
+
+	// Validate the job now instead of failing on every run
+	resp, err := Spawn(rpc_messages.Spawn{
+		Name:         cs.Name,
+		Data:         cs.Data,
+		GuildID:      guildId,
+		ValidateOnly: true,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+	}
+
+	if resp == nil || resp.Validation == nil {
+		return nil, fmt.Errorf("%w: failed to validate job", ErrInvalidSchedule)
+	}
+
+	if !resp.Validation.Valid {
+		return nil, fmt.Errorf("%w: failed to validate job: %s", ErrInvalidSchedule, resp.Validation.Error)
+	}
+
+	var count int
+	err = state.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM schedules WHERE guild_id = $1", guildId).Scan(&count)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if count >= MaxSchedulesPerGuild {
+		return nil, ErrTooManySchedules
+	}
+
+	rows, err := state.Pool.Query(
+		ctx,
+		"INSERT INTO schedules (guild_id, name, data, cron, missed_run_policy, next_run_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING "+scheduleColsStr,
+		guildId,
+		cs.Name,
+		cs.Data,
+		cs.Cron,
+		cs.MissedRunPolicy,
+		nextRunAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[types.Schedule])
+}
+
+// ListSchedules returns all schedules of a guild, oldest first
+func ListSchedules(ctx context.Context, guildId string) ([]types.Schedule, error) {
+	rows, err := state.Pool.Query(ctx, "SELECT "+scheduleColsStr+" FROM schedules WHERE guild_id = $1 ORDER BY created_at, id", guildId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[types.Schedule])
+}
+
+// DeleteSchedule deletes a schedule of a guild
+//
+// Returns pgx.ErrNoRows if the guild has no such schedule
+func DeleteSchedule(ctx context.Context, guildId, id string) error {
+	tag, err := state.Pool.Exec(ctx, "DELETE FROM schedules WHERE guild_id = $1 AND id::text = $2", guildId, id)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package jobrunner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/Anti-Raid/jobserver/pkg/server/signature"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/anti-raid/eureka/jsonimpl"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	// How many times a callback is attempted before giving up
+	callbackAttempts = 5
+
+	// Delay before the first retry of a callback, doubled on every further retry
+	callbackRetryDelay = 2 * time.Second
+
+	callbackClient = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// Never go through a proxy, the address being dialed must be the one checked below
+			Proxy: nil,
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: checkCallbackAddr,
+			}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errors.New("callbacks may not be redirected")
+		},
+	}
+)
+
+// ValidateCallbackURL checks that callbacks are enabled and that a callback URL is https and, if configured, on an allowed host
+func ValidateCallbackURL(callbackUrl string) error {
+	if state.Config.Jobserver.CallbackSecret == "" {
+		return fmt.Errorf("callbacks are disabled as no callback_secret is configured")
+	}
+
+	u, err := url.Parse(callbackUrl)
+
+	if err != nil {
+		return fmt.Errorf("invalid callback url provided")
+	}
+
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("callback url must be an https url")
+	}
+
+	if len(state.Config.Jobserver.CallbackAllowedHosts) > 0 && !slices.Contains(state.Config.Jobserver.CallbackAllowedHosts, u.Hostname()) {
+		return fmt.Errorf("callback url host is not allowed")
+	}
+
+	return nil
+}
+
+// checkCallbackAddr refuses connections to non-public addresses, this runs after DNS resolution so
+// a public hostname resolving to e.g. 127.0.0.1 or a cloud metadata address is refused as well
+func checkCallbackAddr(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("callbacks to %s are not allowed", ip)
+	}
+
+	return nil
+}
+
+// sendCallback POSTs the result of a finished job to its callback URL, if it has one
+func sendCallback(payload rpc_messages.JobCallback) {
+	var callbackUrl *string
+	err := state.Pool.QueryRow(state.Context, "SELECT callback_url FROM jobs WHERE id = $1", payload.ID).Scan(&callbackUrl)
+
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			state.Logger.Error("Failed to fetch job callback url", zap.String("id", payload.ID), zap.Error(err))
+		}
+
+		return
+	}
+
+	if callbackUrl == nil || *callbackUrl == "" {
+		return
+	}
+
+	body, err := jsonimpl.Marshal(payload)
+
+	if err != nil {
+		state.Logger.Error("Failed to marshal job callback", zap.String("id", payload.ID), zap.Error(err))
+		return
+	}
+
+	delay := callbackRetryDelay
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err = postCallback(*callbackUrl, body)
+
+		if err == nil {
+			return
+		}
+
+		state.Logger.Warn("Failed to send job callback", zap.String("id", payload.ID), zap.Int("attempt", attempt), zap.Error(err))
+
+		if attempt == callbackAttempts {
+			break
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-state.Context.Done():
+			return
+		}
+
+		delay *= 2
+	}
+
+	state.Logger.Error("Giving up on job callback", zap.String("id", payload.ID), zap.String("url", *callbackUrl))
+}
+
+// postCallback sends a single signed callback request, any non-2xx response is an error
+func postCallback(url string, body []byte) error {
+	req, err := http.NewRequestWithContext(state.Context, http.MethodPost, url, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	signature.SignRequest(req, state.Config.Jobserver.CallbackSecret, body)
+
+	resp, err := callbackClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
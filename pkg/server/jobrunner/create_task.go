@@ -2,21 +2,51 @@ package jobrunner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/Anti-Raid/jobserver/interfaces"
 	jobs "github.com/Anti-Raid/jobserver/jobs"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/jackc/pgx/v5"
 )
 
+// CreateOpts are optional settings for creating a job
+type CreateOpts struct {
+	// If set, the most recent job of the guild created with the same idempotency key within
+	// IdempotencyWindow is returned instead of creating a new job
+	IdempotencyKey    string
+	IdempotencyWindow time.Duration
+
+	// If set, the job POSTs its result to this URL once it finishes
+	CallbackURL string
+
+	// If set, the job is created as scheduled and should only be executed once this time is reached
+	RunAt *time.Time
+
+	// If set, the job is created as waiting and should only be executed once the job with this ID has completed
+	DependsOn string
+
+	// Who spawned the job
+	Initiator *types.Initiator
+}
+
+// TxBeginner is either a pool or a transaction (in which case Create uses a savepoint)
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
 // Sets up a job
-func Create(ctx context.Context, pool *pgxpool.Pool, jobImpl interfaces.JobImpl, guildId string) (*string, error) {
+//
+// Returns the ID of the job and whether or not the job already existed (through its idempotency key)
+func Create(ctx context.Context, pool TxBeginner, jobImpl interfaces.JobImpl, guildId string, opts CreateOpts) (*string, bool, error) {
 	name := jobImpl.Name()
 
 	_, ok := jobs.JobImplRegistry[jobImpl.Name()]
 
 	if !ok {
-		return nil, fmt.Errorf("job %s does not exist on registry", jobImpl.Name())
+		return nil, false, fmt.Errorf("job %s does not exist on registry", jobImpl.Name())
 	}
 
 	var id string
@@ -24,23 +54,73 @@ func Create(ctx context.Context, pool *pgxpool.Pool, jobImpl interfaces.JobImpl,
 	tx, err := pool.Begin(ctx)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %w", err)
+		return nil, false, fmt.Errorf("failed to start transaction: %w", err)
 	}
 
 	//nolint:errcheck
 	defer tx.Rollback(ctx)
 
-	err = tx.QueryRow(ctx, "INSERT INTO jobs (name, guild_id, expiry, output, fields, resumable) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+	var idempotencyKey *string
+	if opts.IdempotencyKey != "" {
+		idempotencyKey = &opts.IdempotencyKey
+
+		// Serialize spawns with the same key so concurrent retries cannot both create a job
+		_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))", guildId, opts.IdempotencyKey)
+
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to lock idempotency key: %w", err)
+		}
+
+		err = tx.QueryRow(
+			ctx,
+			"SELECT id FROM jobs WHERE guild_id = $1 AND idempotency_key = $2 AND created_at > NOW() - make_interval(secs => $3) ORDER BY created_at DESC LIMIT 1",
+			guildId,
+			opts.IdempotencyKey,
+			opts.IdempotencyWindow.Seconds(),
+		).Scan(&id)
+
+		if err == nil {
+			return &id, true, nil
+		}
+
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, fmt.Errorf("failed to look up idempotency key: %w", err)
+		}
+	}
+
+	initialState := "pending"
+	if opts.RunAt != nil {
+		initialState = "scheduled"
+	}
+
+	var dependsOn *string
+	if opts.DependsOn != "" {
+		initialState = "waiting"
+		dependsOn = &opts.DependsOn
+	}
+
+	var callbackUrl *string
+	if opts.CallbackURL != "" {
+		callbackUrl = &opts.CallbackURL
+	}
+
+	err = tx.QueryRow(ctx, "INSERT INTO jobs (name, guild_id, expiry, output, fields, resumable, idempotency_key, callback_url, run_at, state, depends_on, initiator) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id",
 		name,
 		guildId,
 		jobImpl.Expiry(),
 		nil,
 		jobImpl.Fields(),
 		jobImpl.Resumable(),
+		idempotencyKey,
+		callbackUrl,
+		opts.RunAt,
+		initialState,
+		dependsOn,
+		opts.Initiator,
 	).Scan(&id)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create job: %w", err)
+		return nil, false, fmt.Errorf("failed to create job: %w", err)
 	}
 
 	// Add to ongoing_jobs
@@ -54,14 +134,14 @@ func Create(ctx context.Context, pool *pgxpool.Pool, jobImpl interfaces.JobImpl,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to add job to ongoing_jobs: %w", err)
+		return nil, false, fmt.Errorf("failed to add job to ongoing_jobs: %w", err)
 	}
 
 	err = tx.Commit(ctx)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	return &id, nil
+	return &id, false, nil
 }
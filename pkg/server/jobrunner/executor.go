@@ -5,14 +5,21 @@ package jobrunner
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"runtime/debug"
+	"time"
 
+	"github.com/Anti-Raid/jobserver/common"
 	"github.com/Anti-Raid/jobserver/interfaces"
 	jobs "github.com/Anti-Raid/jobserver/jobs"
 	"github.com/Anti-Raid/jobserver/objectstorage"
+	"github.com/Anti-Raid/jobserver/pkg/server/constraints"
+	"github.com/Anti-Raid/jobserver/pkg/server/metrics"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
 	"github.com/Anti-Raid/jobserver/pkg/server/state"
 	jobstate "github.com/Anti-Raid/jobserver/state"
+	"github.com/Anti-Raid/jobserver/types"
 	"github.com/anti-raid/eureka/crypto"
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
@@ -52,10 +59,18 @@ func GetPersistedState(tc *Progress) (*jobstate.Progress, error) {
 	}, nil
 }
 
+// ConstraintProvider resolves the constraints of jobs on the jobserver, replace this before starting the jobserver
+// to resolve constraints from elsewhere
+var ConstraintProvider jobstate.ConstraintProvider = constraints.PostgresProvider{}
+
 // Implementor of jobs.State
 type JobrunnerState struct {
 	Ctx     context.Context
 	GuildId string
+	JobName string
+
+	// The executing job, unset during validation
+	job *runningJob
 }
 
 func (j JobrunnerState) Transport() *http.Transport {
@@ -84,6 +99,24 @@ func (t JobrunnerState) GuildID() string {
 	return t.GuildId
 }
 
+func (t JobrunnerState) Interrupted() bool {
+	return t.job != nil && t.job.interrupted.Load()
+}
+
+func (JobrunnerState) ConstraintProvider() jobstate.ConstraintProvider {
+	return ConstraintProvider
+}
+
+func (t JobrunnerState) ObserveStep(step string, duration time.Duration, err error) {
+	result := "ok"
+
+	if err != nil {
+		result = "error"
+	}
+
+	metrics.StepDuration.WithLabelValues(t.JobName, step, result).Observe(duration.Seconds())
+}
+
 type Progress struct {
 	ID string
 
@@ -98,27 +131,36 @@ type Progress struct {
 	//
 	// If unset, calls PersistState
 	OnSetProgress func(tc *Progress, prog *jobstate.Progress) error
+
+	// Used to rate limit progress reports and estimate when the current step finishes
+	lastReportAt   time.Time
+	reportStep     string
+	stepStartedAt  time.Time
+	stepItemsStart int
 }
 
-func (ts Progress) GetProgress() (*jobstate.Progress, error) {
+// How often progress reports are persisted at most, reports starting or finishing a step are always persisted
+var progressReportInterval = 2 * time.Second
+
+func (ts *Progress) GetProgress() (*jobstate.Progress, error) {
 	if ts.CurrentProgress == nil {
-		return GetPersistedState(&ts)
+		return GetPersistedState(ts)
 	}
 
 	return ts.CurrentProgress, nil
 }
 
-func (ts Progress) SetProgress(prog *jobstate.Progress) error {
+func (ts *Progress) SetProgress(prog *jobstate.Progress) error {
 	ts.CurrentProgress = prog
 
 	if ts.OnSetProgress != nil {
-		err := ts.OnSetProgress(&ts, prog)
+		err := ts.OnSetProgress(ts, prog)
 
 		if err != nil {
 			return err
 		}
 	} else {
-		err := PersistState(&ts, prog)
+		err := PersistState(ts, prog)
 
 		if err != nil {
 			return err
@@ -128,6 +170,55 @@ func (ts Progress) SetProgress(prog *jobstate.Progress) error {
 	return nil
 }
 
+// ReportProgress persists a progress report to the job, estimating when the current step finishes if no ETA is set
+func (ts *Progress) ReportProgress(report types.ProgressReport) error {
+	now := time.Now()
+
+	newStep := ts.stepStartedAt.IsZero() || report.Step != ts.reportStep
+
+	if newStep {
+		ts.reportStep = report.Step
+		ts.stepStartedAt = now
+		ts.stepItemsStart = report.ItemsDone // Items done before a resume would skew the estimate
+	}
+
+	stepDone := report.ItemsTotal > 0 && report.ItemsDone >= report.ItemsTotal
+
+	if !newStep && !stepDone && now.Sub(ts.lastReportAt) < progressReportInterval {
+		return nil
+	}
+
+	if report.ETA == nil && report.ItemsDone < report.ItemsTotal {
+		if done := report.ItemsDone - ts.stepItemsStart; done > 0 {
+			perItem := now.Sub(ts.stepStartedAt) / time.Duration(done)
+			eta := now.Add(perItem * time.Duration(report.ItemsTotal-report.ItemsDone))
+			report.ETA = &eta
+		}
+	}
+
+	report.Percent = progressPercent(report)
+	ts.lastReportAt = now
+
+	_, err := state.Pool.Exec(ts.State.Context(), "UPDATE jobs SET progress = $1 WHERE id = $2", report, ts.ID)
+
+	return err
+}
+
+// progressPercent returns how far along a job is overall, counting every step as an equal share
+func progressPercent(report types.ProgressReport) float64 {
+	var stepFraction float64
+
+	if report.ItemsTotal > 0 {
+		stepFraction = min(float64(report.ItemsDone)/float64(report.ItemsTotal), 1)
+	}
+
+	if report.StepsTotal <= 0 || report.StepIndex <= 0 {
+		return stepFraction * 100
+	}
+
+	return (float64(report.StepIndex-1) + stepFraction) / float64(report.StepsTotal) * 100
+}
+
 // Creates a new job on server and executes it
 //
 // If prog is set, it will be used to cache the progress, otherwise a blank one will be used
@@ -143,10 +234,27 @@ func Execute(
 		panic("cannot execute jobs outside of job server")
 	}
 
+	executing.Add(1)
+	defer executing.Done()
+
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	rj := &runningJob{
+		cancel:    cancelCause,
+		resumable: jobImpl.Resumable(),
+	}
+	rj.interrupted.Store(rj.resumable && draining.Load())
+	runningJobs.Store(id, rj)
+
 	l, _ := NewTaskLogger(id, state.Pool, ctx, state.Logger)
 	erl, _ := NewTaskLogger(id, state.Pool, state.Context, state.Logger)
 
 	var done bool
+	var startedAt time.Time    // When the job started running, zero if it never did
+	var endState string        // The state the job ended in
+	var outputFilename string  // The filename of the saved output, if any
+	var errSummary string      // Why the job failed, if it did
+	var jobErr *types.JobError // Why the job failed, as shown to users
+	var keepOngoing bool       // Interrupted, paused and retried jobs are kept in ongoing_jobs so they can be resumed
 	var bChan = make(chan int) // bChan is a channel thats used to control the canceller channel
 
 	// Fail failed jobs
@@ -157,59 +265,138 @@ func Execute(
 			erl.Error("Panic", zap.Any("err", err))
 			state.Logger.Error("Panic", zap.Any("err", err))
 
-			_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1 WHERE id = $2", "failed", id)
+			errSummary = "job panicked"
+			jobErr = types.NewJobError(types.JobErrorCodeInternal, "an internal error occurred", nil)
+
+			_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1, error = $2 WHERE id = $3", "failed", jobErr, id)
 
 			if err != nil {
-				l.Error("Failed to update job", zap.Error(err))
+				erl.Error("Failed to update job", zap.Error(err))
 			}
 		}
 
-		if !done {
-			_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1 WHERE id = $2", "failed", id)
+		// Another node has taken over the job, leave it alone
+		leaseLost := errors.Is(context.Cause(ctx), ErrLeaseLost)
+
+		if leaseLost {
+			endState = "lease_lost"
+		}
+
+		if !done && !leaseLost {
+			currState := finalState(ctx, jobImpl, "failed")
+			keepOngoing = currState == "interrupted" || currState == "paused" || currState == "scheduled"
+			endState = currState
+
+			if jobErr == nil {
+				jobErr = stateJobError(currState)
+			}
+
+			_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1, error = $2 WHERE id = $3", currState, jobErr, id)
 
 			if err != nil {
-				l.Error("Failed to update job", zap.Error(err))
+				erl.Error("Failed to update job", zap.Error(err))
 			}
 		}
 
+		if !startedAt.IsZero() {
+			metrics.JobsFinished.WithLabelValues(jobImpl.Name(), endState).Inc()
+			metrics.JobDuration.WithLabelValues(jobImpl.Name(), endState).Observe(time.Since(startedAt).Seconds())
+		}
+
+		// Interrupted, paused and retried jobs are executed again later on and will send their callback then
+		if !leaseLost && endState != "interrupted" && endState != "paused" && endState != "scheduled" {
+			if errSummary == "" && endState != "completed" {
+				if cause := context.Cause(ctx); cause != nil {
+					errSummary = cause.Error()
+				}
+			}
+
+			go sendCallback(rpc_messages.JobCallback{
+				ID:             id,
+				Name:           jobImpl.Name(),
+				GuildID:        guildId,
+				State:          endState,
+				OutputFilename: outputFilename,
+				Error:          errSummary,
+				JobError:       jobErr,
+			})
+		}
+
+		runningJobs.Delete(id)
+		cancelCause(nil)
+		closeStatusSubscribers(id)
+
 		if ctxCancel != nil {
 			defer ctxCancel()
 		}
 
-		_, err2 := state.Pool.Exec(state.Context, "DELETE FROM ongoing_jobs WHERE id = $1", id)
+		if !leaseLost {
+			if !keepOngoing {
+				_, err2 := state.Pool.Exec(state.Context, "DELETE FROM ongoing_jobs WHERE id = $1", id)
 
-		if err != nil {
-			l.Error("Failed to delete job from ongoing jobs", zap.Error(err2))
-			return
+				if err2 != nil {
+					erl.Error("Failed to delete job from ongoing jobs", zap.Error(err2))
+				}
+			}
+
+			release(id)
 		}
 
-		bChan <- 1
+		close(bChan)
 	}()
 
 	go func() {
-		for {
-			select {
-			case <-bChan:
-				return
-			case <-ctx.Done():
+		select {
+		case <-bChan:
+			return
+		case <-ctx.Done():
+			switch cause := context.Cause(ctx); {
+			case errors.Is(cause, ErrJobCancelled):
+				erl.Info("Job cancelled")
+			case errors.Is(cause, ErrShuttingDown):
+				erl.Warn("Job stopped as the jobserver is shutting down")
+			case errors.Is(cause, ErrLeaseLost):
+				erl.Warn("Job stopped as its lease was taken over by another node")
+			case errors.Is(cause, ErrJobTimedOut):
+				erl.Error("Job timed out")
+			default:
 				erl.Error("Context done, timeout?")
-				done = true
-				return
 			}
 		}
 	}()
 
-	// Set state to running
-	_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1 WHERE id = $2", "running", id)
+	// Set state to running, unless the job was cancelled before it could start or another node has taken it over
+	tag, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1 WHERE id = $2 AND state != $3 AND lease_owner = $4", "running", id, "cancelled", NodeID)
 
 	if err != nil {
 		l.Error("Failed to update job", zap.Error(err))
 		return
 	}
 
+	if tag.RowsAffected() == 0 {
+		var jobState string
+		err = state.Pool.QueryRow(state.Context, "SELECT state FROM jobs WHERE id = $1", id).Scan(&jobState)
+
+		if err == nil && jobState != "cancelled" {
+			erl.Warn("Job is leased by another node, not executing it")
+			cancelCause(ErrLeaseLost)
+			return
+		}
+
+		erl.Info("Job was cancelled before it could start")
+		endState = "cancelled"
+		done = true
+		return
+	}
+
+	startedAt = time.Now()
+	metrics.JobsStarted.WithLabelValues(jobImpl.Name()).Inc()
+
 	ts := JobrunnerState{
 		Ctx:     ctx,
 		GuildId: guildId,
+		JobName: jobImpl.Name(),
+		job:     rj,
 	}
 
 	if prog == nil {
@@ -220,14 +407,49 @@ func Execute(
 	}
 
 	var currState = "completed"
+	var retryIn time.Duration // How long to wait before retrying the job, if it is rescheduled
 
 	outp, terr := jobImpl.Exec(l, ts, prog)
 
-	if terr != nil {
-		l.Error("Failed to execute job [terr != nil]", zap.Error(terr))
-		currState = "failed"
+	if errors.Is(context.Cause(ctx), ErrLeaseLost) {
+		return
 	}
 
+	if errors.Is(terr, jobstate.ErrInterrupted) && rj.paused.Load() {
+		erl.Info("Job paused, it will continue once resumed")
+		currState = "paused"
+	} else if errors.Is(terr, jobstate.ErrInterrupted) {
+		erl.Info("Job interrupted, it will be resumed later")
+		currState = "interrupted"
+	} else if terr != nil {
+		erl.Error("Failed to execute job [terr != nil]", zap.Error(terr))
+		currState = finalState(ctx, jobImpl, "failed")
+		errSummary = terr.Error()
+
+		switch currState {
+		case "failed":
+			jobErr = common.ClassifyError(terr)
+		case "timed_out":
+			// The job itself usually only sees context.DeadlineExceeded
+			errSummary = ErrJobTimedOut.Error() + " after " + time.Since(startedAt).Round(time.Second).String()
+			jobErr = stateJobError(currState)
+		}
+
+		if currState == "failed" {
+			backoff, ok, err := retryBackoff(ctx, id, jobImpl, terr)
+
+			if err != nil {
+				erl.Error("Failed to check whether job should be retried", zap.Error(err))
+			} else if ok {
+				erl.Warn("Retrying job", zap.Duration("backoff", backoff))
+				currState = "scheduled"
+				retryIn = backoff
+			}
+		}
+	}
+
+	keepOngoing = currState == "interrupted" || currState == "paused" || currState == "scheduled"
+
 	// Save output to object storage
 	if outp != nil {
 		if outp.Filename == "" {
@@ -239,31 +461,81 @@ func Execute(
 		if outp.Buffer == nil {
 			l.Error("Job output buffer is nil")
 			currState = "failed"
+			errSummary = "job output buffer is nil"
+			jobErr = types.NewJobError(types.JobErrorCodeInternal, "an internal error occurred", nil)
 		} else {
 			l.Info("Saving job output", zap.String("filename", outp.Filename))
 
+			outputSize := outp.Buffer.Len() // Save drains the buffer
+
 			err = state.ObjectStorage.Save(
 				state.Context,
 				objectstorage.GuildBucket(guildId),
 				jobs.GetPathFromOutput(id),
 				outp.Filename,
 				outp.Buffer,
-				0,
+				outputExpiry(jobImpl),
 			)
 
 			if err != nil {
 				l.Error("Failed to save backup", zap.Error(err))
+				errSummary = "failed to save job output: " + err.Error()
 				return
 			}
+
+			outputFilename = outp.Filename
+
+			metrics.ObjectStorageBytesSaved.WithLabelValues(jobImpl.Name()).Add(float64(outputSize))
 		}
 	}
 
-	_, err = state.Pool.Exec(state.Context, "UPDATE jobs SET output = $1, state = $2 WHERE id = $3", outp, currState, id)
+	if currState == "scheduled" {
+		err = reschedule(id, jobImpl, retryIn)
+	} else {
+		_, err = state.Pool.Exec(state.Context, "UPDATE jobs SET output = $1, state = $2, error = $3 WHERE id = $4", outp, currState, jobErr, id)
+	}
 
 	if err != nil {
 		l.Error("Failed to update job", zap.Error(err))
 		return
 	}
 
+	endState = currState
 	done = true
 }
+
+// finalState returns the state a job should end in, taking cancellation and shutdowns into account
+func finalState(ctx context.Context, jobImpl interfaces.JobImpl, def string) string {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, ErrJobCancelled):
+		return "cancelled"
+	case errors.Is(cause, ErrJobTimedOut):
+		return "timed_out"
+	case errors.Is(cause, ErrShuttingDown) && jobImpl.Resumable():
+		// Resumable jobs persist their progress and can continue on the next boot
+		return "interrupted"
+	}
+
+	return def
+}
+
+// stateJobError returns the job error of a job that ended in a state without an error of its own, if any
+func stateJobError(jobState string) *types.JobError {
+	switch jobState {
+	case "failed":
+		return types.NewJobError(types.JobErrorCodeInternal, "an internal error occurred", nil)
+	case "timed_out":
+		return types.NewJobError(types.JobErrorCodeTimedOut, "the job took too long and was stopped", nil)
+	}
+
+	return nil
+}
+
+// outputExpiry returns how long the output of a job should be kept for, 0 meaning forever
+func outputExpiry(jobImpl interfaces.JobImpl) time.Duration {
+	if expiry := jobImpl.Expiry(); expiry != nil {
+		return *expiry
+	}
+
+	return 0
+}
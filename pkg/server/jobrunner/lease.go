@@ -0,0 +1,156 @@
+package jobrunner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/anti-raid/eureka/crypto"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrJobLeased is returned when trying to execute a job leased by another node
+	ErrJobLeased = errors.New("job is leased by another jobserver node")
+
+	// ErrLeaseLost is the cause set on the context of a job whose lease was taken over by another node
+	ErrLeaseLost = errors.New("job lease lost to another jobserver node")
+)
+
+// Fallback for when lease_duration_secs is not set
+var DefaultLeaseDuration = 60 * time.Second
+
+// NodeID identifies this jobserver process as the owner of job leases
+var NodeID = newNodeID()
+
+func newNodeID() string {
+	hostname, err := os.Hostname()
+
+	if err != nil {
+		hostname = "jobserver"
+	}
+
+	return hostname + "-" + crypto.RandString(8)
+}
+
+// LeaseDuration returns how long a lease is valid for without being renewed
+func LeaseDuration() time.Duration {
+	if state.Config.Jobserver.LeaseDurationSecs <= 0 {
+		return DefaultLeaseDuration
+	}
+
+	return time.Duration(state.Config.Jobserver.LeaseDurationSecs) * time.Second
+}
+
+// claim leases a job to this node, unless another node holds an unexpired lease on it
+func claim(ctx context.Context, id string) (bool, error) {
+	tag, err := state.Pool.Exec(
+		ctx,
+		"UPDATE jobs SET lease_owner = $1, lease_expires_at = NOW() + make_interval(secs => $2) WHERE id = $3 AND (lease_owner IS NULL OR lease_owner = $1 OR lease_expires_at IS NULL OR lease_expires_at < NOW())",
+		NodeID,
+		LeaseDuration().Seconds(),
+		id,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// release gives up the lease of a job so that other nodes can pick it up right away
+func release(id string) {
+	_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET lease_owner = NULL, lease_expires_at = NULL WHERE id = $1 AND lease_owner = $2", id, NodeID)
+
+	if err != nil {
+		state.Logger.Error("Failed to release job lease", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// Heartbeat periodically renews the leases of all jobs queued on or executing in this node until ctx is done
+//
+// ctx should only be cancelled once Drain has returned, as leases of jobs still running would otherwise expire
+func Heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(LeaseDuration() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := renewLeases(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			state.Logger.Error("Failed to renew job leases", zap.Error(err))
+		}
+	}
+}
+
+type renewedLease struct {
+	ID    string `db:"id"`
+	State string `db:"state"`
+}
+
+// renewLeases extends the leases held by this node, stopping jobs that were cancelled or taken over by another node
+func renewLeases(ctx context.Context) error {
+	ids := queue.ids()
+
+	runningJobs.Range(func(id string, _ *runningJob) bool {
+		ids = append(ids, id)
+		return true
+	})
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := state.Pool.Query(
+		ctx,
+		"UPDATE jobs SET lease_expires_at = NOW() + make_interval(secs => $1) WHERE id = ANY($2) AND lease_owner = $3 RETURNING id, state",
+		LeaseDuration().Seconds(),
+		ids,
+		NodeID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	renewed, err := pgx.CollectRows(rows, pgx.RowToStructByName[renewedLease])
+
+	if err != nil {
+		return err
+	}
+
+	held := make(map[string]string, len(renewed))
+	for _, r := range renewed {
+		held[r.ID] = r.State
+	}
+
+	runningJobs.Range(func(id string, rj *runningJob) bool {
+		jobState, ok := held[id]
+
+		switch {
+		case !ok:
+			state.Logger.Warn("Lost lease of running job, stopping it", zap.String("id", id))
+			rj.cancel(ErrLeaseLost)
+		case jobState == "cancelled":
+			// Cancelled through another node
+			rj.cancel(ErrJobCancelled)
+		case jobState == "pausing":
+			// Paused through another node
+			Pause(id)
+		}
+
+		return true
+	})
+
+	return nil
+}
@@ -39,12 +39,18 @@ func (m *MutLogger) add(p []byte) error {
 	}
 
 	// For us, this is just an array append of the json
-	_, err = m.pool.Exec(m.ctx, "UPDATE jobs SET statuses = array_append(statuses, $1), last_updated = NOW() WHERE id = $2", data, m.id)
+	var index int
+	err = m.pool.QueryRow(m.ctx, "UPDATE jobs SET statuses = array_append(statuses, $1), last_updated = NOW() WHERE id = $2 RETURNING cardinality(statuses)", data, m.id).Scan(&index)
 
 	if err != nil {
 		return fmt.Errorf("failed to update statuses: %w", err)
 	}
 
+	publishStatus(m.id, StatusEvent{
+		Index:  index,
+		Status: data,
+	})
+
 	return nil
 }
 
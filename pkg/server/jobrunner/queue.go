@@ -0,0 +1,226 @@
+package jobrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/interfaces"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"go.uber.org/zap"
+)
+
+// DefaultWorkers is the number of workers used if none are configured
+var DefaultWorkers = 4
+
+// ErrQueueStopped is returned when queueing a job once the jobrunner has started draining
+var ErrQueueStopped = errors.New("job queue is stopped, not accepting new jobs")
+
+// A job waiting for a worker to execute it
+type QueuedJob struct {
+	ID      string
+	Job     interfaces.JobImpl
+	Prog    *Progress
+	GuildID string
+
+	// Jobs with a higher priority are executed first, jobs of equal priority are executed in the order they were queued
+	Priority int
+
+	// How long the job may execute for once a worker has picked it up
+	Timeout time.Duration
+
+	seq uint64
+}
+
+// jobQueue holds queued jobs ordered by priority
+type jobQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	jobs    []*QueuedJob   // Sorted by priority (descending), then seq (ascending)
+	running map[string]int // job name -> number of jobs with the name currently executing
+	caps    map[string]int // job name -> maximum number of concurrently executing jobs with the name
+	nextSeq uint64
+	stopped bool
+}
+
+var queue = newJobQueue()
+
+func newJobQueue() *jobQueue {
+	q := &jobQueue{
+		running: map[string]int{},
+		caps:    map[string]int{},
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Enqueue leases a job to this node and queues it to be executed by a worker, marking it as queued
+//
+// Returns ErrJobLeased if another node holds the lease of the job and ErrQueueStopped once draining has started
+func Enqueue(qj QueuedJob) error {
+	// Checked before claiming so that a draining node does not take jobs away from other nodes
+	if draining.Load() {
+		return ErrQueueStopped
+	}
+
+	ok, err := claim(state.Context, qj.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to lease job: %w", err)
+	}
+
+	if !ok {
+		return ErrJobLeased
+	}
+
+	_, err = state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1 WHERE id = $2 AND state != $3", "queued", qj.ID, "cancelled")
+
+	if err != nil {
+		state.Logger.Error("Failed to mark job as queued", zap.String("id", qj.ID), zap.Error(err))
+	}
+
+	err = queue.push(&qj)
+
+	if err != nil {
+		// Draining started after the check above, leave the job for another node (or the next boot) like Drain does
+		release(qj.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (q *jobQueue) push(qj *QueuedJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return ErrQueueStopped
+	}
+
+	qj.seq = q.nextSeq
+	q.nextSeq++
+
+	i, _ := slices.BinarySearchFunc(q.jobs, qj, func(a, b *QueuedJob) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+
+		return int(a.seq) - int(b.seq)
+	})
+
+	q.jobs = slices.Insert(q.jobs, i, qj)
+	q.cond.Signal()
+
+	return nil
+}
+
+// pop waits for the highest priority job whose name is below its concurrency cap
+//
+// Returns nil once the queue has been stopped
+func (q *jobQueue) pop() *QueuedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if q.stopped {
+			return nil
+		}
+
+		for i, qj := range q.jobs {
+			name := qj.Job.Name()
+
+			if limit, ok := q.caps[name]; ok && limit > 0 && q.running[name] >= limit {
+				continue
+			}
+
+			q.jobs = slices.Delete(q.jobs, i, i+1)
+			q.running[name]++
+			return qj
+		}
+
+		q.cond.Wait()
+	}
+}
+
+// done marks a job popped from the queue as finished, allowing other jobs with the same name to run
+func (q *jobQueue) done(qj *QueuedJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.running[qj.Job.Name()]--
+
+	// Wake up all workers as any of them may be waiting on the concurrency cap of this job
+	q.cond.Broadcast()
+}
+
+// stop makes all workers exit once they finish their current job, returning the IDs of the jobs still queued
+func (q *jobQueue) stop() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	q.cond.Broadcast()
+
+	ids := make([]string, 0, len(q.jobs))
+	for _, qj := range q.jobs {
+		ids = append(ids, qj.ID)
+	}
+
+	q.jobs = nil
+
+	return ids
+}
+
+// ids returns the IDs of all queued jobs
+func (q *jobQueue) ids() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]string, 0, len(q.jobs))
+	for _, qj := range q.jobs {
+		ids = append(ids, qj.ID)
+	}
+
+	return ids
+}
+
+// StartWorkers starts the workers that execute queued jobs
+//
+// caps limits how many jobs of a name may execute at once, a cap of 0 means no limit
+func StartWorkers(workers int, caps map[string]int) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	queue.mu.Lock()
+	for name, limit := range caps {
+		queue.caps[name] = limit
+	}
+	queue.mu.Unlock()
+
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+}
+
+func worker() {
+	for {
+		qj := queue.pop()
+
+		if qj == nil {
+			return
+		}
+
+		// The timeout only starts once the job actually starts executing
+		ctx, cancel := context.WithTimeoutCause(state.Context, qj.Timeout, ErrJobTimedOut)
+
+		Execute(ctx, cancel, qj.ID, qj.Job, qj.Prog, qj.GuildID)
+
+		queue.done(qj)
+	}
+}
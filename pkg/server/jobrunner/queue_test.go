@@ -0,0 +1,178 @@
+package jobrunner
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/interfaces"
+)
+
+// testJob is a job that is only ever queued, never executed
+type testJob struct {
+	interfaces.JobImpl
+	name string
+}
+
+func (j testJob) Name() string {
+	return j.name
+}
+
+func newQueuedJob(id, name string, priority int) *QueuedJob {
+	return &QueuedJob{ID: id, Job: testJob{name: name}, Priority: priority}
+}
+
+// popIDs pops n jobs from the queue, failing if any pop blocks
+func popIDs(t *testing.T, q *jobQueue, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for range n {
+		qj := popWithin(t, q, time.Second)
+
+		if qj == nil {
+			t.Fatalf("pop blocked after %v", ids)
+		}
+
+		ids = append(ids, qj.ID)
+	}
+
+	return ids
+}
+
+// popWithin pops a job from the queue, returning nil if none could be popped within timeout
+func popWithin(t *testing.T, q *jobQueue, timeout time.Duration) *QueuedJob {
+	t.Helper()
+
+	popped := make(chan *QueuedJob, 1)
+	go func() { popped <- q.pop() }()
+
+	select {
+	case qj := <-popped:
+		return qj
+	case <-time.After(timeout):
+		// Unblock the pop so the goroutine does not leak
+		q.stop()
+		return nil
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		jobs []*QueuedJob
+		want []string
+	}{
+		{
+			name: "fifo within a priority",
+			jobs: []*QueuedJob{
+				newQueuedJob("a", "backup", 0),
+				newQueuedJob("b", "prune", 0),
+				newQueuedJob("c", "backup", 0),
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "higher priority first",
+			jobs: []*QueuedJob{
+				newQueuedJob("low", "backup", -1),
+				newQueuedJob("normal", "prune", 0),
+				newQueuedJob("high", "restore", 10),
+				newQueuedJob("normal2", "backup", 0),
+				newQueuedJob("high2", "prune", 10),
+			},
+			want: []string{"high", "high2", "normal", "normal2", "low"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newJobQueue()
+
+			for _, qj := range tt.jobs {
+				if err := q.push(qj); err != nil {
+					t.Fatalf("push(%s): unexpected error: %v", qj.ID, err)
+				}
+			}
+
+			if got := popIDs(t, q, len(tt.want)); !slices.Equal(got, tt.want) {
+				t.Errorf("pop order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueCaps(t *testing.T) {
+	q := newJobQueue()
+	q.caps["backup"] = 1
+	q.caps["prune"] = 0 // No limit
+
+	for _, qj := range []*QueuedJob{
+		newQueuedJob("backup1", "backup", 5),
+		newQueuedJob("backup2", "backup", 5),
+		newQueuedJob("prune1", "prune", 0),
+		newQueuedJob("prune2", "prune", 0),
+	} {
+		if err := q.push(qj); err != nil {
+			t.Fatalf("push(%s): unexpected error: %v", qj.ID, err)
+		}
+	}
+
+	// backup2 has a higher priority but must wait for backup1, so the prune jobs go ahead of it
+	first := popWithin(t, q, time.Second)
+
+	if first == nil || first.ID != "backup1" {
+		t.Fatalf("first pop = %v, want backup1", first)
+	}
+
+	if got, want := popIDs(t, q, 2), []string{"prune1", "prune2"}; !slices.Equal(got, want) {
+		t.Fatalf("pop order = %v, want %v", got, want)
+	}
+
+	popped := make(chan *QueuedJob, 1)
+	go func() { popped <- q.pop() }()
+
+	select {
+	case qj := <-popped:
+		t.Fatalf("popped %s while backup1 is still running", qj.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.done(first)
+
+	select {
+	case qj := <-popped:
+		if qj == nil || qj.ID != "backup2" {
+			t.Fatalf("pop after done = %v, want backup2", qj)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("backup2 was not popped once backup1 was done")
+	}
+}
+
+func TestQueueStop(t *testing.T) {
+	q := newJobQueue()
+
+	for _, qj := range []*QueuedJob{newQueuedJob("a", "backup", 0), newQueuedJob("b", "backup", 1)} {
+		if err := q.push(qj); err != nil {
+			t.Fatalf("push(%s): unexpected error: %v", qj.ID, err)
+		}
+	}
+
+	if got, want := q.stop(), []string{"b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("stop = %v, want %v", got, want)
+	}
+
+	if err := q.push(newQueuedJob("c", "backup", 0)); !errors.Is(err, ErrQueueStopped) {
+		t.Errorf("push after stop = %v, want %v", err, ErrQueueStopped)
+	}
+
+	if qj := popWithin(t, q, time.Second); qj != nil {
+		t.Errorf("pop after stop = %s, want nil", qj.ID)
+	}
+
+	if ids := q.ids(); len(ids) != 0 {
+		t.Errorf("ids after stop = %v, want none", ids)
+	}
+}
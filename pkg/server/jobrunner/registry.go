@@ -0,0 +1,124 @@
+package jobrunner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/utils/syncmap"
+)
+
+var (
+	// ErrJobCancelled is the cause set on the context of a job cancelled through the API
+	ErrJobCancelled = errors.New("job cancelled")
+
+	// ErrShuttingDown is the cause set on the context of jobs still executing once the drain grace period has passed
+	ErrShuttingDown = errors.New("jobserver shutting down")
+
+	// ErrJobTimedOut is the cause set on the context of jobs executing for longer than their timeout
+	ErrJobTimedOut = errors.New("job timed out")
+)
+
+// How long to wait for jobs to record their final state after being cancelled during a drain
+var drainCancelWait = 10 * time.Second
+
+// runningJob is a job currently executing in this process
+type runningJob struct {
+	cancel    context.CancelCauseFunc
+	resumable bool
+
+	// Set when the job should stop at its next step boundary
+	interrupted atomic.Bool
+
+	// Set when the job was interrupted by a pause rather than a shutdown
+	paused atomic.Bool
+}
+
+// runningJobs stores all jobs currently executing in this process
+var runningJobs = syncmap.Map[string, *runningJob]{} // jobID -> job
+
+// executing tracks Execute calls that have not yet finished recording their final state
+var executing sync.WaitGroup
+
+// draining is set once Drain has been called, jobs starting afterwards are interrupted immediately
+var draining atomic.Bool
+
+// Cancel cancels a job executing in this process
+//
+// Returns false if the job is not executing in this process
+func Cancel(id string) bool {
+	rj, ok := runningJobs.Load(id)
+
+	if !ok {
+		return false
+	}
+
+	rj.cancel(ErrJobCancelled)
+	return true
+}
+
+// Pause asks a resumable job executing in this process to stop at its next step boundary, keeping its progress
+//
+// Returns false if the job is not executing in this process or is not resumable
+func Pause(id string) bool {
+	rj, ok := runningJobs.Load(id)
+
+	if !ok || !rj.resumable {
+		return false
+	}
+
+	rj.paused.Store(true)
+	rj.interrupted.Store(true)
+	return true
+}
+
+// waitIdle waits until all jobs have finished executing or the timeout passes, returning false on timeout
+func waitIdle(timeout time.Duration) bool {
+	idle := make(chan struct{})
+
+	go func() {
+		executing.Wait()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Drain stops all jobs executing in this process along with the workers executing queued jobs
+//
+// Resumable jobs are asked to stop at their next step boundary and are kept in ongoing_jobs so that they
+// are resumed on the next boot. Jobs still executing after the grace period are cancelled
+func Drain(grace time.Duration) {
+	draining.Store(true)
+
+	// Queued jobs that have not started yet are left in ongoing_jobs for another node (or the next boot) to pick up
+	for _, id := range queue.stop() {
+		release(id)
+	}
+
+	runningJobs.Range(func(id string, rj *runningJob) bool {
+		if rj.resumable {
+			rj.interrupted.Store(true)
+		}
+
+		return true
+	})
+
+	if waitIdle(grace) {
+		return
+	}
+
+	runningJobs.Range(func(id string, rj *runningJob) bool {
+		rj.cancel(ErrShuttingDown)
+		return true
+	})
+
+	waitIdle(drainCancelWait)
+}
@@ -0,0 +1,71 @@
+package jobrunner
+
+import (
+	"context"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/common"
+	"github.com/Anti-Raid/jobserver/interfaces"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+)
+
+// retryBackoff returns how long to wait before retrying a failed job, if its retry policy allows retrying it
+func retryBackoff(ctx context.Context, id string, jobImpl interfaces.JobImpl, terr error) (time.Duration, bool, error) {
+	retryable, ok := jobImpl.(interfaces.RetryableJobImpl)
+
+	if !ok {
+		return 0, false, nil
+	}
+
+	// Cancelled, timed out and interrupted jobs are never retried
+	if ctx.Err() != nil {
+		return 0, false, nil
+	}
+
+	policy := retryable.RetryPolicy()
+
+	isRetryable := policy.IsRetryable
+	if isRetryable == nil {
+		isRetryable = common.IsRetryableError
+	}
+
+	if !isRetryable(terr) {
+		return 0, false, nil
+	}
+
+	var attempt int
+	err := state.Pool.QueryRow(state.Context, "SELECT attempt FROM jobs WHERE id = $1", id).Scan(&attempt)
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	if !policy.ShouldRetry(attempt) {
+		return 0, false, nil
+	}
+
+	return policy.Backoff(attempt), true, nil
+}
+
+// reschedule schedules a failed job to be retried once backoff has passed
+func reschedule(id string, jobImpl interfaces.JobImpl, backoff time.Duration) error {
+	// Jobs that cannot be resumed are retried from scratch
+	if !jobImpl.Resumable() {
+		_, err := state.Pool.Exec(state.Context, "UPDATE ongoing_jobs SET data = $1 WHERE id = $2", map[string]any{}, id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// The scheduler may pick up the job as soon as this is done
+	_, err := state.Pool.Exec(
+		state.Context,
+		"UPDATE jobs SET output = NULL, progress = NULL, state = $1, run_at = NOW() + make_interval(secs => $2), attempt = attempt + 1 WHERE id = $3",
+		"scheduled",
+		backoff.Seconds(),
+		id,
+	)
+
+	return err
+}
@@ -0,0 +1,83 @@
+package jobrunner
+
+import "sync"
+
+// statusSubscriberBuffer is the number of status entries that can be buffered per subscriber
+//
+// Subscribers that fall further behind are dropped and must catch up from the database
+const statusSubscriberBuffer = 256
+
+// StatusEvent is a status entry of a job
+type StatusEvent struct {
+	// The (1-indexed) position of the status in jobs.statuses
+	Index  int
+	Status map[string]any
+}
+
+type statusSubscribers struct {
+	sync.Mutex
+	subs map[string]map[chan StatusEvent]struct{} // jobID -> subscribers
+}
+
+var subscribers = statusSubscribers{
+	subs: map[string]map[chan StatusEvent]struct{}{},
+}
+
+// SubscribeStatuses subscribes to the status entries of a job as they are written by this process
+//
+// The returned channel is closed once the job finishes executing, when the subscriber falls too far behind
+// or when the returned unsubscribe function is called
+func SubscribeStatuses(id string) (<-chan StatusEvent, func()) {
+	ch := make(chan StatusEvent, statusSubscriberBuffer)
+
+	subscribers.Lock()
+	if subscribers.subs[id] == nil {
+		subscribers.subs[id] = map[chan StatusEvent]struct{}{}
+	}
+	subscribers.subs[id][ch] = struct{}{}
+	subscribers.Unlock()
+
+	return ch, func() {
+		subscribers.Lock()
+		defer subscribers.Unlock()
+
+		if _, ok := subscribers.subs[id][ch]; ok {
+			removeSubscriber(id, ch)
+		}
+	}
+}
+
+// removeSubscriber removes and closes a subscriber, the caller must hold the subscribers lock
+func removeSubscriber(id string, ch chan StatusEvent) {
+	delete(subscribers.subs[id], ch)
+	close(ch)
+
+	if len(subscribers.subs[id]) == 0 {
+		delete(subscribers.subs, id)
+	}
+}
+
+// publishStatus sends a status entry to all subscribers of a job
+func publishStatus(id string, ev StatusEvent) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+
+	for ch := range subscribers.subs[id] {
+		select {
+		case ch <- ev:
+		default:
+			// Subscriber is too slow, drop it
+			removeSubscriber(id, ch)
+		}
+	}
+}
+
+// closeStatusSubscribers closes all subscribers of a job
+func closeStatusSubscribers(id string) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+
+	for ch := range subscribers.subs[id] {
+		removeSubscriber(id, ch)
+	}
+}
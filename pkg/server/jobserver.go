@@ -1,27 +1,48 @@
 package jobserver
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/jobrunner"
+	"github.com/Anti-Raid/jobserver/pkg/server/reaper"
 	"github.com/Anti-Raid/jobserver/pkg/server/rpc"
+	"github.com/Anti-Raid/jobserver/pkg/server/scheduler"
 	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"go.uber.org/zap"
 )
 
+// Fallback for when shutdown_grace_period_secs is not set
+var defaultShutdownGracePeriod = 60 * time.Second
+
+// How long in-flight RPC requests may take to complete on shutdown
+var rpcShutdownTimeout = 10 * time.Second
+
+// Stops renewing job leases, called once running jobs have been drained
+var stopHeartbeat context.CancelFunc = func() {}
+
 func CreateJobServer() {
-	// Set state of all pending tasks to 'failed'
-	_, err := state.Pool.Exec(state.Context, "UPDATE jobs SET state = $1 WHERE state = $2", "failed", "pending")
+	state.Logger.Info("Starting jobserver node", zap.String("node_id", jobrunner.NodeID))
 
-	if err != nil {
-		panic(err)
-	}
+	jobrunner.StartWorkers(state.Config.Jobserver.Workers, state.Config.Jobserver.JobConcurrency)
+
+	var heartbeatCtx context.Context
+	heartbeatCtx, stopHeartbeat = context.WithCancel(state.Context)
+
+	go jobrunner.Heartbeat(heartbeatCtx)
 
 	go rpc.JobserverRpcServer()
 
-	// Resume ongoing jobs
+	// Resume ongoing jobs orphaned by a previous run, other nodes are recovered by the scheduler
 	go core.Resume()
+
+	go scheduler.Run()
+
+	go reaper.Run()
 }
 
 func LaunchJobserver() {
@@ -38,7 +59,26 @@ func LaunchJobserver() {
 
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 
-	<-c
+	sig := <-c
+
+	grace := time.Duration(state.Config.Jobserver.ShutdownGracePeriodSecs) * time.Second
+
+	if grace <= 0 {
+		grace = defaultShutdownGracePeriod
+	}
+
+	state.Logger.Info("Shutting down jobserver, draining running jobs", zap.String("signal", sig.String()), zap.Duration("grace", grace))
+
+	core.StopSpawning()
+	jobrunner.Drain(grace)
+	stopHeartbeat()
+
+	ctx, cancel := context.WithTimeout(context.Background(), rpcShutdownTimeout)
+	defer cancel()
+
+	rpc.Shutdown(ctx)
+
+	state.Logger.Info("Jobserver stopped")
 }
 
 func main() {
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DiscordTransport records metrics for all requests made through it
+type DiscordTransport struct {
+	next http.RoundTripper
+}
+
+func NewDiscordTransport(next http.RoundTripper) *DiscordTransport {
+	return &DiscordTransport{
+		next: next,
+	}
+}
+
+func (t DiscordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+
+	if err != nil {
+		DiscordRequests.WithLabelValues(req.Method, "error").Inc()
+		return nil, err
+	}
+
+	DiscordRequests.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		scope := resp.Header.Get("X-RateLimit-Scope")
+
+		if scope == "" {
+			scope = "unknown"
+		}
+
+		DiscordRateLimits.WithLabelValues(scope).Inc()
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,50 @@
+// Package metrics defines the Prometheus metrics exported by the jobserver
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultBuckets are histogram buckets (in seconds) suited to jobs, which may run from seconds to hours
+var DefaultBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200}
+
+// Metrics exported by the jobserver
+var (
+	JobsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobserver_jobs_started_total",
+		Help: "Number of jobs that started executing",
+	}, []string{"name"})
+
+	JobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobserver_jobs_finished_total",
+		Help: "Number of jobs that finished executing, by final state",
+	}, []string{"name", "state"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "jobserver_job_duration_seconds",
+		Help:    "How long jobs took to execute, by final state",
+		Buckets: DefaultBuckets,
+	}, []string{"name", "state"})
+
+	StepDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "jobserver_step_duration_seconds",
+		Help:    "How long individual steps of jobs took to execute",
+		Buckets: DefaultBuckets,
+	}, []string{"name", "step", "result"})
+
+	ObjectStorageBytesSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobserver_object_storage_saved_bytes_total",
+		Help: "Number of bytes of job outputs saved to object storage",
+	}, []string{"name"})
+
+	DiscordRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobserver_discord_requests_total",
+		Help: "Number of HTTP requests made to Discord, by status code",
+	}, []string{"method", "code"})
+
+	DiscordRateLimits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobserver_discord_rate_limits_total",
+		Help: "Number of HTTP requests to Discord that were rate limited, by rate limit scope",
+	}, []string{"scope"})
+)
@@ -0,0 +1,160 @@
+// Package reaper deletes finished jobs (and their outputs) once they have expired
+package reaper
+
+import (
+	"context"
+	"time"
+
+	jobs "github.com/Anti-Raid/jobserver/jobs"
+	"github.com/Anti-Raid/jobserver/objectstorage"
+	"github.com/Anti-Raid/jobserver/pkg/server/audit"
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	// How often expired jobs are looked for
+	ReapInterval = 10 * time.Minute
+
+	// Maximum number of expired jobs deleted per batch
+	BatchSize = 100
+)
+
+// Run periodically reaps expired jobs until the jobserver stops accepting jobs
+func Run() {
+	ticker := time.NewTicker(ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		if !core.Accepting() {
+			return
+		}
+
+		for core.Accepting() {
+			n, err := reap(state.Context)
+
+			if err != nil {
+				state.Logger.Error("Failed to reap expired jobs", zap.Error(err))
+				break
+			}
+
+			// Jobs whose outputs failed to delete are picked up again, so stop once a batch makes no progress
+			if n == 0 {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-core.Stopping():
+			return
+		}
+	}
+}
+
+type expiredJob struct {
+	ID        string    `db:"id"`
+	Name      string    `db:"name"`
+	GuildID   string    `db:"guild_id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// reap deletes a batch of expired jobs along with their outputs, returning the number of jobs reaped
+//
+// Outputs are deleted before any rows are locked as object storage can be slow, a failure leaves
+// the job around to be retried on the next run
+func reap(ctx context.Context) (int, error) {
+	// Only finished jobs are reaped, a job still executing past its expiry is reaped once it finishes
+	rows, err := state.Pool.Query(
+		ctx,
+		"SELECT id, name, guild_id, created_at FROM jobs WHERE expiry IS NOT NULL AND created_at + expiry < NOW() AND state = ANY($1) ORDER BY created_at LIMIT $2",
+		core.FinishedStates(),
+		BatchSize,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	expired, err := pgx.CollectRows(rows, pgx.RowToStructByName[expiredJob])
+
+	if err != nil {
+		return 0, err
+	}
+
+	outputsDeleted := map[string]expiredJob{}
+	var ids []string
+
+	for _, j := range expired {
+		err = state.ObjectStorage.Delete(ctx, objectstorage.GuildBucket(j.GuildID), jobs.GetPathFromOutput(j.ID), "")
+
+		if err != nil {
+			state.Logger.Error("Failed to delete output of expired job", zap.String("id", j.ID), zap.String("guild_id", j.GuildID), zap.Error(err))
+			continue
+		}
+
+		outputsDeleted[j.ID] = j
+		ids = append(ids, j.ID)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := state.Pool.Begin(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	//nolint:errcheck
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM ongoing_jobs WHERE id = ANY($1)", ids)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Another reaper may have got to some of these jobs first, only jobs deleted here count as reaped
+	rows, err = tx.Query(ctx, "DELETE FROM jobs WHERE id = ANY($1) AND state = ANY($2) RETURNING id", ids, core.FinishedStates())
+
+	if err != nil {
+		return 0, err
+	}
+
+	deletedIds, err := pgx.CollectRows(rows, pgx.RowTo[string])
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range deletedIds {
+		j := outputsDeleted[id]
+
+		state.Logger.Info("Reaped expired job", zap.String("id", j.ID), zap.String("name", j.Name), zap.String("guild_id", j.GuildID), zap.Time("created_at", j.CreatedAt))
+
+		audit.LogOrWarn(ctx, audit.Entry{
+			Event:   audit.EventDelete,
+			JobID:   j.ID,
+			GuildID: j.GuildID,
+			Actor: types.Initiator{
+				Source: types.InitiatorSourceReaper,
+			},
+			Data: map[string]any{
+				"name": j.Name,
+			},
+		})
+	}
+
+	return len(deletedIds), nil
+}
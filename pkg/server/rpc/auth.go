@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/signature"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"go.uber.org/zap"
+)
+
+var (
+	// Fallback for when signature_max_age_secs is not set
+	defaultSignatureMaxAge = 60 * time.Second
+
+	// Maximum size of a signed request body
+	maxSignedBodySize int64 = 10 * 1024 * 1024
+)
+
+// Paths that can be requested without a signature, used by orchestrators for health checks and metrics scraping
+var unsignedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+func signatureMaxAge() time.Duration {
+	if state.Config.Jobserver.SignatureMaxAgeSecs <= 0 {
+		return defaultSignatureMaxAge
+	}
+
+	return time.Duration(state.Config.Jobserver.SignatureMaxAgeSecs) * time.Second
+}
+
+// pgNonceStore stores nonces in postgres so that they are shared between all jobserver nodes
+type pgNonceStore struct{}
+
+func (pgNonceStore) Seen(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	tag, err := state.Pool.Exec(ctx, "INSERT INTO request_nonces (nonce, expires_at) VALUES ($1, $2) ON CONFLICT (nonce) DO NOTHING", nonce, expiresAt)
+
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 0, nil
+}
+
+func (pgNonceStore) ForgetExpired(ctx context.Context) error {
+	_, err := state.Pool.Exec(ctx, "DELETE FROM request_nonces WHERE expires_at < NOW()")
+	return err
+}
+
+// forgetExpiredNonces periodically removes nonces that are too old to be replayed anyways
+func forgetExpiredNonces(verifier *signature.Verifier) {
+	ticker := time.NewTicker(verifier.MaxAge)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := verifier.Nonces.ForgetExpired(state.Context)
+
+		if err != nil {
+			state.Logger.Warn("Failed to forget expired nonces", zap.Error(err))
+		}
+	}
+}
+
+// requireSignature rejects all requests that are not signed with the shared secret
+func requireSignature(next http.Handler) http.Handler {
+	verifier := &signature.Verifier{
+		Secret: state.Config.Jobserver.SharedSecret,
+		MaxAge: signatureMaxAge(),
+		Nonces: pgNonceStore{},
+	}
+
+	go forgetExpiredNonces(verifier)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unsignedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize))
+
+		if err != nil {
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+
+		err = verifier.VerifyRequest(r, body)
+
+		if errors.Is(err, signature.ErrNonceStore) {
+			state.Logger.Error("Failed to verify request", zap.String("path", r.URL.Path), zap.Error(err))
+			http.Error(w, "Error verifying request", http.StatusInternalServerError)
+			return
+		}
+
+		if err != nil {
+			if errors.Is(err, signature.ErrInvalidSignature) {
+				state.Logger.Warn("Rejected request with invalid signature", zap.String("path", r.URL.Path), zap.String("remote_addr", r.RemoteAddr))
+			}
+
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/anti-raid/eureka/jsonimpl"
+)
+
+// getHealth is a liveness check, it does not check any dependencies so an outage of one does not get the jobserver restarted
+func getHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, _ = w.Write([]byte("OK"))
+}
+
+// getReadiness reports whether all dependencies are up, failing if any of them is down or the jobserver is not accepting new jobs
+func getReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := core.CheckHealth()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !resp.Healthy || !resp.Accepting {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err := jsonimpl.MarshalToWriter(w, resp)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,321 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/anti-raid/eureka/jsonimpl"
+	"github.com/jackc/pgx/v5"
+)
+
+// intQuery parses an optional integer query parameter, returning def if it is not set
+func intQuery(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	if i < 0 {
+		return 0, fmt.Errorf("%s cannot be negative", name)
+	}
+
+	return i, nil
+}
+
+// timeQuery parses an optional RFC3339 timestamp query parameter, returning nil if it is not set
+func timeQuery(r *http.Request, name string) (*time.Time, error) {
+	v := r.URL.Query().Get(name)
+
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+
+	return &t, nil
+}
+
+// initiator returns who is taking an action through a request, as set by the X-Initiator-User-Id and
+// X-Initiator-Source headers
+func initiator(r *http.Request) types.Initiator {
+	i := types.Initiator{
+		UserID: r.Header.Get("X-Initiator-User-Id"),
+		Source: r.Header.Get("X-Initiator-Source"),
+	}
+
+	if i.Source == "" {
+		i.Source = types.InitiatorSourceAPI
+	}
+
+	return i
+}
+
+func getJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	job, err := core.GetJob(r.Context(), r.PathValue("id"))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching job: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = jsonimpl.MarshalToWriter(w, job)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func getJobStatuses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offset, err := intQuery(r, "offset", 0)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, err := intQuery(r, "limit", core.DefaultStatusesLimit)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if limit == 0 || limit > core.MaxStatusesLimit {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", core.MaxStatusesLimit), http.StatusBadRequest)
+		return
+	}
+
+	statuses, total, err := core.GetJobStatuses(r.Context(), r.PathValue("id"), offset, limit)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching job statuses: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = jsonimpl.MarshalToWriter(w, rpc_messages.JobStatusesResponse{
+		Statuses: statuses,
+		Total:    total,
+		Offset:   offset,
+		Limit:    limit,
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func getJobOutput(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	u, err := core.GetOutputUrl(r.Context(), r.PathValue("id"), initiator(r))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, core.ErrNoOutput) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching job output: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = jsonimpl.MarshalToWriter(w, rpc_messages.JobOutputResponse{
+		URL:       u.String(),
+		ExpiresAt: time.Now().Add(core.OutputUrlExpiry),
+	})
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func cancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := core.CancelJob(r.Context(), r.PathValue("id"), initiator(r))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, core.ErrJobFinished) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error cancelling job: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func pauseJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := core.PauseJob(r.Context(), r.PathValue("id"))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, core.ErrJobFinished) || errors.Is(err, core.ErrJobNotPausable) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error pausing job: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The job stops at its next step boundary
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func resumeJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !core.Accepting() {
+		http.Error(w, core.ErrDraining.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	err := core.ResumePausedJob(r.Context(), r.PathValue("id"))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if errors.Is(err, core.ErrJobFinished) || errors.Is(err, core.ErrJobNotPaused) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error resuming job: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listGuildJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, err := intQuery(r, "limit", core.DefaultJobListLimit)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if limit == 0 || limit > core.MaxJobListLimit {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", core.MaxJobListLimit), http.StatusBadRequest)
+		return
+	}
+
+	createdAfter, err := timeQuery(r, "created_after")
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	createdBefore, err := timeQuery(r, "created_before")
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := core.ListGuildJobs(r.Context(), r.PathValue("guild_id"), core.JobListFilter{
+		Name:          r.URL.Query().Get("name"),
+		State:         r.URL.Query().Get("state"),
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+		Cursor:        r.URL.Query().Get("cursor"),
+		Limit:         limit,
+	})
+
+	if errors.Is(err, core.ErrInvalidCursor) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listing jobs: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = jsonimpl.MarshalToWriter(w, resp)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
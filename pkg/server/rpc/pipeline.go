@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/anti-raid/eureka/jsonimpl"
+)
+
+func spawnPipeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var pipeline rpc_messages.SpawnPipeline
+
+	err := jsonimpl.UnmarshalReader(r.Body, &pipeline)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := core.SpawnPipeline(pipeline)
+
+	if errors.Is(err, core.ErrDraining) {
+		http.Error(w, fmt.Sprintf("Error spawning pipeline: %s", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error spawning pipeline: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = jsonimpl.MarshalToWriter(w, resp)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	jobs "github.com/Anti-Raid/jobserver/jobs"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/anti-raid/eureka/jsonimpl"
+)
+
+// getRegistry returns all jobs on the job registry along with a schema of their options
+func getRegistry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var entries = make([]rpc_messages.RegistryEntry, 0, len(jobs.JobImplRegistry))
+
+	for name, jobImpl := range jobs.JobImplRegistry {
+		fieldsSafe, secret := jobs.OptionFieldSafety(jobImpl)
+
+		entries = append(entries, rpc_messages.RegistryEntry{
+			Name:       name,
+			Resumable:  jobImpl.Resumable(),
+			Schema:     jobs.OptionsSchema(jobImpl),
+			FieldsSafe: fieldsSafe,
+			Secret:     secret,
+		})
+	}
+
+	slices.SortFunc(entries, func(a, b rpc_messages.RegistryEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	err := jsonimpl.MarshalToWriter(w, entries)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
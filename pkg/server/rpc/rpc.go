@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,8 +11,12 @@ import (
 	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
 	"github.com/Anti-Raid/jobserver/pkg/server/state"
 	"github.com/anti-raid/eureka/jsonimpl"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 )
 
+var server = &http.Server{}
+
 func JobserverRpcServer() {
 	handler := http.NewServeMux()
 
@@ -38,6 +44,11 @@ func JobserverRpcServer() {
 		// Spawn job
 		resp, err := core.Spawn(spawn)
 
+		if errors.Is(err, core.ErrDraining) {
+			http.Error(w, fmt.Sprintf("Error spawning job: %s", err), http.StatusServiceUnavailable)
+			return
+		}
+
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error spawning job: %s", err), http.StatusInternalServerError)
 			return
@@ -52,10 +63,39 @@ func JobserverRpcServer() {
 		}
 	})
 
+	handler.HandleFunc("/spawn/pipeline", spawnPipeline)
+	handler.HandleFunc("/jobs/{id}", getJob)
+	handler.HandleFunc("/jobs/{id}/statuses", getJobStatuses)
+	handler.HandleFunc("/jobs/{id}/output", getJobOutput)
+	handler.HandleFunc("/jobs/{id}/cancel", cancelJob)
+	handler.HandleFunc("/jobs/{id}/pause", pauseJob)
+	handler.HandleFunc("/jobs/{id}/resume", resumeJob)
+	handler.HandleFunc("/jobs/{id}/stream", streamJob)
+	handler.HandleFunc("/guilds/{guild_id}/jobs", listGuildJobs)
+	handler.HandleFunc("/guilds/{guild_id}/schedules", guildSchedules)
+	handler.HandleFunc("/guilds/{guild_id}/schedules/{id}", deleteSchedule)
+	handler.HandleFunc("/registry", getRegistry)
+	handler.HandleFunc("/healthz", getHealth)
+	handler.HandleFunc("/readyz", getReadiness)
+	handler.Handle("/metrics", promhttp.Handler())
+
 	// Start server
-	err := http.ListenAndServe(":"+strconv.Itoa(state.Config.BasePorts.Jobserver), handler)
+	server.Addr = ":" + strconv.Itoa(state.Config.BasePorts.Jobserver)
+	server.Handler = requireSignature(handler)
 
-	if err != nil {
+	err := server.ListenAndServe()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic(err)
 	}
 }
+
+// Shutdown gracefully stops the RPC server, forcefully closing remaining connections once ctx is done
+func Shutdown(ctx context.Context) {
+	err := server.Shutdown(ctx)
+
+	if err != nil {
+		state.Logger.Warn("Failed to gracefully shutdown RPC server", zap.Error(err))
+		_ = server.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/anti-raid/eureka/jsonimpl"
+	"github.com/jackc/pgx/v5"
+)
+
+func guildSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listSchedules(w, r)
+	case http.MethodPost:
+		createSchedule(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := core.ListSchedules(r.Context(), r.PathValue("guild_id"))
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error listing schedules: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = jsonimpl.MarshalToWriter(w, schedules)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func createSchedule(w http.ResponseWriter, r *http.Request) {
+	var cs rpc_messages.CreateSchedule
+
+	err := jsonimpl.UnmarshalReader(r.Body, &cs)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading request: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := core.CreateSchedule(r.Context(), r.PathValue("guild_id"), cs)
+
+	if errors.Is(err, core.ErrInvalidSchedule) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, core.ErrTooManySchedules) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error creating schedule: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	err = jsonimpl.MarshalToWriter(w, schedule)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error writing response: %s", err), http.StatusInternalServerError)
+		return
+	}
+}
+
+func deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := core.DeleteSchedule(r.Context(), r.PathValue("guild_id"), r.PathValue("id"))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting schedule: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,218 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/jobrunner"
+	"github.com/anti-raid/eureka/jsonimpl"
+	"github.com/jackc/pgx/v5"
+)
+
+// How often to catch up on statuses from the database while streaming
+//
+// This picks up statuses written by other processes and notices when a job finishes
+var streamPollInterval = 5 * time.Second
+
+// statusStream writes job statuses to a client as server-sent events
+type statusStream struct {
+	w       http.ResponseWriter
+	r       *http.Request
+	flusher http.Flusher
+	id      string
+
+	// The index of the last status sent to the client
+	lastIndex int
+}
+
+func (s *statusStream) send(event string, id int, data any) error {
+	b, err := jsonimpl.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	if id > 0 {
+		_, err = fmt.Fprintf(s.w, "id: %d\n", id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, b)
+
+	if err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// catchUp sends all statuses after lastIndex from the database
+func (s *statusStream) catchUp() error {
+	for {
+		statuses, total, err := core.GetJobStatuses(s.r.Context(), s.id, s.lastIndex, core.MaxStatusesLimit)
+
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			s.lastIndex++
+
+			err = s.send("status", s.lastIndex, status)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(statuses) == 0 || s.lastIndex >= total {
+			return nil
+		}
+	}
+}
+
+// finished catches up on statuses and returns whether or not the job has finished
+func (s *statusStream) finished() (bool, error) {
+	// Fetch the state first so no statuses written before the job finished are missed
+	jobState, err := core.GetJobState(s.r.Context(), s.id)
+
+	if err != nil {
+		return false, err
+	}
+
+	err = s.catchUp()
+
+	if err != nil {
+		return false, err
+	}
+
+	if !core.IsFinished(jobState) {
+		return false, nil
+	}
+
+	return true, s.send("end", 0, map[string]string{"state": jobState})
+}
+
+// streamJob streams the statuses of a job as server-sent events
+//
+// The backlog of statuses is sent first, followed by new statuses as they are written.
+// Clients may resume a stream by setting the Last-Event-ID header
+func streamJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	s := &statusStream{
+		w:       w,
+		r:       r,
+		flusher: flusher,
+		id:      r.PathValue("id"),
+	}
+
+	if lastEventId := r.Header.Get("Last-Event-ID"); lastEventId != "" {
+		idx, err := strconv.Atoi(lastEventId)
+
+		if err != nil || idx < 0 {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+
+		s.lastIndex = idx
+	}
+
+	_, err := core.GetJobState(r.Context(), s.id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching job: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before sending the backlog so no statuses are lost in between
+	events, unsubscribe := jobrunner.SubscribeStatuses(s.id)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	done, err := s.finished()
+
+	if err != nil || done {
+		return
+	}
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				// Job finished executing or we fell behind, the database is now the source of truth
+				events = nil
+
+				done, err := s.finished()
+
+				if err != nil || done {
+					return
+				}
+
+				continue
+			}
+
+			// Already sent as part of the backlog
+			if ev.Index <= s.lastIndex {
+				continue
+			}
+
+			// Statuses were missed, catch up first
+			if ev.Index > s.lastIndex+1 {
+				err := s.catchUp()
+
+				if err != nil {
+					return
+				}
+
+				continue
+			}
+
+			s.lastIndex = ev.Index
+
+			err := s.send("status", ev.Index, ev.Status)
+
+			if err != nil {
+				return
+			}
+		case <-ticker.C:
+			done, err := s.finished()
+
+			if err != nil || done {
+				return
+			}
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package rpc_messages
 
 import (
+	"time"
+
 	_ "github.com/Anti-Raid/jobserver/state" // Avoid unsafe import
+	"github.com/Anti-Raid/jobserver/types"
 )
 
 // Spawns a job and executes it if the execute argument is set.
@@ -11,13 +14,139 @@ type Spawn struct {
 	Create  bool                   `json:"create"`
 	Execute bool                   `json:"execute"`
 
+	// If set, only validates the job, returning the effective data of the job. Create and execute are ignored
+	ValidateOnly bool `json:"validate_only"`
+
 	// If create is false, then task id must be set
 	ID string `json:"id"`
 
+	// If set, repeating a spawn with the same idempotency key and guild within the idempotency window
+	// returns the existing job instead of creating (and executing) a new one
+	IdempotencyKey string `json:"idempotency_key"`
+
+	// Jobs with a higher priority are executed first when the jobserver is busy
+	Priority int `json:"priority"`
+
+	// If set (along with create and execute), the job is stored as waiting and only executed once the job with this ID
+	// has completed. String options equal to job://upstream are replaced with the output URL of that job
+	DependsOn string `json:"depends_on"`
+
+	// If set (along with create and execute), the job is stored as scheduled and only executed once this time is reached
+	RunAt *time.Time `json:"run_at"`
+
+	// If set (and create is set), a POST request with a JobCallback signed with the callback secret is sent to this
+	// https URL once the job finishes
+	CallbackURL string `json:"callback_url"`
+
 	// The Guild ID which initiated the action
 	GuildID string `json:"guild_id"`
+
+	// Who spawned the job, the source defaults to api
+	Initiator types.Initiator `json:"initiator"`
 }
 
 type SpawnResponse struct {
 	ID string `json:"id"`
+
+	// Whether or not the job already existed due to the idempotency key
+	Existing bool `json:"existing"`
+
+	// Only set if validate_only is set
+	Validation *SpawnValidation `json:"validation,omitempty"`
+}
+
+// The result of validating a job
+type SpawnValidation struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+
+	// The public fields of the job after validation has filled in defaults
+	Data map[string]any `json:"data"`
+}
+
+// A page of the statuses of a job
+type JobStatusesResponse struct {
+	Statuses []map[string]any `json:"statuses"`
+
+	// The total number of statuses the job has
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// A job on the job registry
+type RegistryEntry struct {
+	Name      string `json:"name"`
+	Resumable bool   `json:"resumable"`
+
+	// JSON schema of the data that can be used to spawn the job
+	Schema map[string]any `json:"schema"`
+
+	// Options that are stored in the jobs public fields
+	FieldsSafe []string `json:"fields_safe"`
+
+	// Options that are secret and omitted from storage
+	Secret []string `json:"secret"`
+}
+
+// The status of a single dependency of the jobserver
+//
+// Only whether the dependency is up or down is exposed, the reason for a failure is logged instead
+type DependencyHealth struct {
+	Status string `json:"status"` // up or down
+}
+
+// The health of the jobserver and all of its dependencies
+type HealthResponse struct {
+	Healthy bool `json:"healthy"`
+
+	// Whether or not the jobserver is accepting new jobs
+	Accepting bool `json:"accepting"`
+
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
+
+// A temporary URL to download the output of a job with
+type JobOutputResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// The payload sent to the callback URL of a job once it finishes
+type JobCallback struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	GuildID string `json:"guild_id"`
+	State   string `json:"state"`
+
+	// The filename of the output of the job, if it has one
+	OutputFilename string `json:"output_filename,omitempty"`
+
+	// A summary of the error that caused the job to fail, if any
+	Error string `json:"error,omitempty"`
+
+	// The error that caused the job to fail as shown to users, if any
+	JobError *types.JobError `json:"job_error,omitempty"`
+}
+
+// Creates a schedule that spawns a job on a cron expression
+type CreateSchedule struct {
+	Name string                 `json:"name"`
+	Data map[string]interface{} `json:"data"`
+
+	// A 5-field cron expression (in UTC) or a macro such as @daily
+	Cron string `json:"cron"`
+
+	// What to do with runs missed while the jobserver was down, either run_once (the default) or skip
+	MissedRunPolicy string `json:"missed_run_policy"`
+}
+
+// Spawns an ordered pipeline of jobs, each job depending on the previous one
+type SpawnPipeline struct {
+	Jobs []Spawn `json:"jobs"`
+}
+
+type SpawnPipelineResponse struct {
+	// The IDs of the jobs of the pipeline, in order
+	IDs []string `json:"ids"`
 }
@@ -0,0 +1,220 @@
+// Package scheduler spawns the jobs of schedules and executes jobs spawned with a run_at or dependency once they are due
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/pkg/server/core"
+	"github.com/Anti-Raid/jobserver/pkg/server/jobrunner"
+	"github.com/Anti-Raid/jobserver/pkg/server/rpc_messages"
+	"github.com/Anti-Raid/jobserver/pkg/server/state"
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+var (
+	// How often due schedules are looked for
+	PollInterval = 30 * time.Second
+
+	// Maximum number of due schedules (and jobs) handled per poll
+	BatchSize = 50
+
+	// A run that is due for longer than this counts as missed
+	MissedRunGrace = 5 * time.Minute
+)
+
+// Run polls for due schedules and jobs until the jobserver stops accepting jobs
+//
+// Schedules and jobs are locked while being handled, so multiple jobservers can safely poll the same database
+func Run() {
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !core.Accepting() {
+			return
+		}
+
+		err := runDue(state.Context)
+
+		if err != nil {
+			state.Logger.Error("Failed to run due schedules", zap.Error(err))
+		}
+
+		err = runDueJobs(state.Context)
+
+		if err != nil {
+			state.Logger.Error("Failed to run due jobs", zap.Error(err))
+		}
+
+		err = core.PromoteWaitingJobs(state.Context)
+
+		if err != nil {
+			state.Logger.Error("Failed to promote waiting jobs", zap.Error(err))
+		}
+
+		err = core.RecoverOrphanedJobs(state.Context)
+
+		if err != nil {
+			state.Logger.Error("Failed to recover orphaned jobs", zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-core.Stopping():
+			return
+		}
+	}
+}
+
+type dueSchedule struct {
+	ID              string         `db:"id"`
+	GuildID         string         `db:"guild_id"`
+	Name            string         `db:"name"`
+	Data            map[string]any `db:"data"`
+	Cron            string         `db:"cron"`
+	MissedRunPolicy string         `db:"missed_run_policy"`
+	NextRunAt       time.Time      `db:"next_run_at"`
+}
+
+// runDue spawns the jobs of all due schedules and moves them to their next run
+func runDue(ctx context.Context) error {
+	tx, err := state.Pool.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	//nolint:errcheck
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(
+		ctx,
+		"SELECT id, guild_id, name, data, cron, missed_run_policy, next_run_at FROM schedules WHERE next_run_at <= NOW() ORDER BY next_run_at LIMIT $1 FOR UPDATE SKIP LOCKED",
+		BatchSize,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	due, err := pgx.CollectRows(rows, pgx.RowToStructByName[dueSchedule])
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, s := range due {
+		nextRunAt, err := core.NextRun(s.Cron, now)
+
+		if err != nil {
+			// The cron expression was validated on creation, so this should never happen
+			state.Logger.Error("Invalid cron expression on schedule", zap.String("id", s.ID), zap.String("cron", s.Cron), zap.Error(err))
+			continue
+		}
+
+		missed := now.Sub(s.NextRunAt) > MissedRunGrace
+
+		if missed && s.MissedRunPolicy == core.MissedRunPolicySkip {
+			state.Logger.Info("Skipping missed schedule run", zap.String("id", s.ID), zap.Time("due", s.NextRunAt))
+
+			_, err = tx.Exec(ctx, "UPDATE schedules SET next_run_at = $1 WHERE id = $2", nextRunAt, s.ID)
+
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		resp, err := core.Spawn(rpc_messages.Spawn{
+			Name:    s.Name,
+			Data:    s.Data,
+			GuildID: s.GuildID,
+			Create:  true,
+			Execute: true,
+			Initiator: types.Initiator{
+				Source: types.InitiatorSourceSchedule,
+			},
+			// Makes sure a run is spawned at most once, even if the transaction fails to commit
+			IdempotencyKey: "schedule:" + s.ID + ":" + strconv.FormatInt(s.NextRunAt.Unix(), 10),
+		})
+
+		if errors.Is(err, core.ErrDraining) {
+			// Leave the schedule due, it is picked up again once a jobserver is accepting jobs
+			return tx.Commit(ctx)
+		}
+
+		var lastJobId *string
+		if err != nil {
+			state.Logger.Error("Failed to spawn scheduled job", zap.String("id", s.ID), zap.String("name", s.Name), zap.Error(err))
+		} else {
+			lastJobId = &resp.ID
+		}
+
+		_, err = tx.Exec(ctx, "UPDATE schedules SET next_run_at = $1, last_run_at = $2, last_job_id = COALESCE($3, last_job_id) WHERE id = $4", nextRunAt, now, lastJobId, s.ID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// runDueJobs claims all jobs scheduled through run_at that are due and queues them
+func runDueJobs(ctx context.Context) error {
+	if !core.Accepting() {
+		return nil
+	}
+
+	// Claiming moves the jobs out of the scheduled state, so every job is only ever claimed once
+	rows, err := state.Pool.Query(
+		ctx,
+		"UPDATE jobs SET state = 'queued' WHERE id IN (SELECT id FROM jobs WHERE state = 'scheduled' AND run_at <= NOW() ORDER BY run_at LIMIT $1 FOR UPDATE SKIP LOCKED) RETURNING id",
+		BatchSize,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err = core.ResumeJob(ctx, id)
+
+		if errors.Is(err, jobrunner.ErrQueueStopped) {
+			// Draining started midway, the remaining jobs are queued without a lease so they are recovered elsewhere
+			return nil
+		}
+
+		if err != nil {
+			state.Logger.Error("Failed to queue scheduled job", zap.String("id", id), zap.Error(err))
+
+			_, err = state.Pool.Exec(ctx, "UPDATE jobs SET state = 'failed' WHERE id = $1", id)
+
+			if err != nil {
+				state.Logger.Error("Failed to mark scheduled job as failed", zap.String("id", id), zap.Error(err))
+			}
+
+			_, err = state.Pool.Exec(ctx, "DELETE FROM ongoing_jobs WHERE id = $1", id)
+
+			if err != nil {
+				state.Logger.Error("Failed to delete scheduled job from ongoing jobs", zap.String("id", id), zap.Error(err))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+// Package signature implements the shared-secret HMAC signatures used by the jobserver
+//
+// A signature is the hex-encoded HMAC-SHA256 (keyed with the shared secret) of the following
+// fields joined by newlines:
+//
+//	<unix timestamp in seconds>
+//	<nonce>
+//	<HTTP method>
+//	<request URI (path and query)>
+//	<hex-encoded SHA256 of the body>
+//
+// The timestamp, nonce and signature are sent in the X-Jobserver-Timestamp, X-Jobserver-Nonce
+// and X-Jobserver-Signature headers respectively
+package signature
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/utils/syncmap"
+	"github.com/anti-raid/eureka/crypto"
+)
+
+const (
+	HeaderTimestamp = "X-Jobserver-Timestamp"
+	HeaderNonce     = "X-Jobserver-Nonce"
+	HeaderSignature = "X-Jobserver-Signature"
+)
+
+var (
+	ErrInvalidTimestamp = errors.New("missing or invalid " + HeaderTimestamp)
+	ErrExpired          = errors.New("request signature has expired")
+	ErrMissingNonce     = errors.New("missing " + HeaderNonce)
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrReplayed         = errors.New("request has already been processed")
+	ErrNonceStore       = errors.New("failed to check request nonce")
+)
+
+// Sign computes the signature of a request
+func Sign(secret string, timestamp int64, nonce, method, requestUri string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		strconv.FormatInt(timestamp, 10),
+		nonce,
+		method,
+		requestUri,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature in constant time
+func Verify(secret string, timestamp int64, nonce, method, requestUri string, body []byte, signature string) bool {
+	expected := Sign(secret, timestamp, nonce, method, requestUri, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// SignRequest signs a request with a fresh timestamp and nonce, setting the signature headers
+//
+// body must be the exact body the request is sent with
+func SignRequest(req *http.Request, secret string, body []byte) {
+	timestamp := time.Now().Unix()
+	nonce := crypto.RandString(32)
+
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, nonce, req.Method, req.URL.RequestURI(), body))
+}
+
+// NonceStore remembers the nonces of accepted requests
+//
+// With multiple jobserver nodes the store must be shared between them, otherwise a request accepted by one node
+// can be replayed against every other node
+type NonceStore interface {
+	// Seen records a nonce until expiresAt, returning whether or not it was already recorded
+	Seen(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+
+	// ForgetExpired removes nonces that are too old to be replayed anyways
+	ForgetExpired(ctx context.Context) error
+}
+
+// MemoryNonceStore is a NonceStore local to the process, only suitable for a single node as nonces are neither
+// shared with other nodes nor kept across restarts
+type MemoryNonceStore struct {
+	nonces syncmap.Map[string, time.Time] // nonce -> time after which the nonce can be forgotten
+}
+
+func (m *MemoryNonceStore) Seen(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	_, seen := m.nonces.LoadOrStore(nonce, expiresAt)
+	return seen, nil
+}
+
+func (m *MemoryNonceStore) ForgetExpired(ctx context.Context) error {
+	now := time.Now()
+	m.nonces.Range(func(nonce string, expiresAt time.Time) bool {
+		if now.After(expiresAt) {
+			m.nonces.Delete(nonce)
+		}
+
+		return true
+	})
+
+	return nil
+}
+
+// Verifier verifies signed requests, rejecting those signed too long ago (or too far in the future) and replays
+type Verifier struct {
+	Secret string
+
+	// How old a signed request may be before it is rejected, nonces are remembered for as long
+	MaxAge time.Duration
+
+	// Where the nonces of accepted requests are remembered to reject replays
+	Nonces NonceStore
+}
+
+// VerifyRequest checks the signature headers of a request, body must be the exact body the request was sent with
+func (v *Verifier) VerifyRequest(req *http.Request, body []byte) error {
+	timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	signedAt := time.Unix(timestamp, 0)
+
+	if time.Since(signedAt).Abs() > v.MaxAge {
+		return ErrExpired
+	}
+
+	nonce := req.Header.Get(HeaderNonce)
+
+	if nonce == "" {
+		return ErrMissingNonce
+	}
+
+	if !Verify(v.Secret, timestamp, nonce, req.Method, req.URL.RequestURI(), body, req.Header.Get(HeaderSignature)) {
+		return ErrInvalidSignature
+	}
+
+	// Only remember nonces of validly signed requests, the nonce can be forgotten once the timestamp has expired
+	seen, err := v.Nonces.Seen(req.Context(), nonce, signedAt.Add(v.MaxAge))
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrNonceStore, err)
+	}
+
+	if seen {
+		return ErrReplayed
+	}
+
+	return nil
+}
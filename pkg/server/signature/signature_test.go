@@ -0,0 +1,196 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+func newSignedRequest(t *testing.T, secret string, body []byte) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, "http://jobserver/spawn?x=1", bytes.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	SignRequest(req, secret, body)
+
+	return req
+}
+
+func newVerifier() *Verifier {
+	return &Verifier{
+		Secret: testSecret,
+		MaxAge: time.Minute,
+		Nonces: &MemoryNonceStore{},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"name":"guild_create_backup"}`)
+	sig := Sign(testSecret, 1700000000, "nonce", http.MethodPost, "/spawn", body)
+
+	tests := []struct {
+		name       string
+		secret     string
+		timestamp  int64
+		nonce      string
+		method     string
+		requestUri string
+		body       []byte
+		want       bool
+	}{
+		{"valid", testSecret, 1700000000, "nonce", http.MethodPost, "/spawn", body, true},
+		{"wrong secret", "other-secret", 1700000000, "nonce", http.MethodPost, "/spawn", body, false},
+		{"tampered timestamp", testSecret, 1700000001, "nonce", http.MethodPost, "/spawn", body, false},
+		{"tampered nonce", testSecret, 1700000000, "other-nonce", http.MethodPost, "/spawn", body, false},
+		{"tampered method", testSecret, 1700000000, "nonce", http.MethodGet, "/spawn", body, false},
+		{"tampered path", testSecret, 1700000000, "nonce", http.MethodPost, "/spawn/pipeline", body, false},
+		{"tampered body", testSecret, 1700000000, "nonce", http.MethodPost, "/spawn", []byte(`{"name":"guild_restore_backup"}`), false},
+		{"empty body", testSecret, 1700000000, "nonce", http.MethodPost, "/spawn", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := Verify(tt.secret, tt.timestamp, tt.nonce, tt.method, tt.requestUri, tt.body, sig); got != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := []byte(`{"name":"guild_create_backup"}`)
+
+	tests := []struct {
+		name   string
+		modify func(req *http.Request)
+		body   []byte
+		want   error
+	}{
+		{"round trip", func(req *http.Request) {}, body, nil},
+		{"tampered body", func(req *http.Request) {}, []byte(`{"name":"guild_restore_backup"}`), ErrInvalidSignature},
+		{"tampered query", func(req *http.Request) { req.URL.RawQuery = "x=2" }, body, ErrInvalidSignature},
+		{"tampered signature", func(req *http.Request) { req.Header.Set(HeaderSignature, "00") }, body, ErrInvalidSignature},
+		{"missing timestamp", func(req *http.Request) { req.Header.Del(HeaderTimestamp) }, body, ErrInvalidTimestamp},
+		{"missing nonce", func(req *http.Request) { req.Header.Del(HeaderNonce) }, body, ErrMissingNonce},
+		{"expired timestamp", func(req *http.Request) { resign(req, time.Now().Add(-2*time.Minute), body) }, body, ErrExpired},
+		{"future timestamp", func(req *http.Request) { resign(req, time.Now().Add(2*time.Minute), body) }, body, ErrExpired},
+		{"slightly old timestamp", func(req *http.Request) { resign(req, time.Now().Add(-30*time.Second), body) }, body, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newSignedRequest(t, testSecret, body)
+			tt.modify(req)
+
+			err := newVerifier().VerifyRequest(req, tt.body)
+
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyRequest = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// resign signs a request again as if it was signed at signedAt, keeping its nonce
+func resign(req *http.Request, signedAt time.Time, body []byte) {
+	nonce := req.Header.Get(HeaderNonce)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(signedAt.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(testSecret, signedAt.Unix(), nonce, req.Method, req.URL.RequestURI(), body))
+}
+
+func TestVerifyRequestReplay(t *testing.T) {
+	v := newVerifier()
+	body := []byte(`{}`)
+	req := newSignedRequest(t, testSecret, body)
+
+	if err := v.VerifyRequest(req, body); err != nil {
+		t.Fatalf("first request: unexpected error: %v", err)
+	}
+
+	if err := v.VerifyRequest(req, body); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("replayed request: got %v, want %v", err, ErrReplayed)
+	}
+
+	// A fresh nonce is not a replay
+	if err := v.VerifyRequest(newSignedRequest(t, testSecret, body), body); err != nil {
+		t.Fatalf("second request: unexpected error: %v", err)
+	}
+}
+
+func TestVerifyRequestInvalidDoesNotBurnNonce(t *testing.T) {
+	v := newVerifier()
+	body := []byte(`{}`)
+	req := newSignedRequest(t, testSecret, body)
+
+	// A forged request reusing a nonce must not stop the genuine request from being accepted
+	if err := v.VerifyRequest(req, []byte(`{"forged":true}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("forged request: got %v, want %v", err, ErrInvalidSignature)
+	}
+
+	if err := v.VerifyRequest(req, body); err != nil {
+		t.Fatalf("genuine request: unexpected error: %v", err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryNonceStore{}
+
+	for _, tt := range []struct {
+		nonce     string
+		expiresAt time.Time
+		wantSeen  bool
+	}{
+		{"expired", time.Now().Add(-time.Second), false},
+		{"fresh", time.Now().Add(time.Minute), false},
+		{"fresh", time.Now().Add(time.Minute), true},
+	} {
+		seen, err := store.Seen(ctx, tt.nonce, tt.expiresAt)
+
+		if err != nil || seen != tt.wantSeen {
+			t.Fatalf("Seen(%q) = %v, %v, want %v", tt.nonce, seen, err, tt.wantSeen)
+		}
+	}
+
+	if err := store.ForgetExpired(ctx); err != nil {
+		t.Fatalf("ForgetExpired: unexpected error: %v", err)
+	}
+
+	if _, ok := store.nonces.Load("expired"); ok {
+		t.Error("expired nonce was not forgotten")
+	}
+
+	if _, ok := store.nonces.Load("fresh"); !ok {
+		t.Error("fresh nonce was forgotten")
+	}
+}
+
+// failingNonceStore fails every operation
+type failingNonceStore struct{}
+
+func (failingNonceStore) Seen(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func (failingNonceStore) ForgetExpired(ctx context.Context) error {
+	return errors.New("store unavailable")
+}
+
+func TestVerifyRequestNonceStoreError(t *testing.T) {
+	v := newVerifier()
+	v.Nonces = failingNonceStore{}
+
+	body := []byte(`{}`)
+
+	if err := v.VerifyRequest(newSignedRequest(t, testSecret, body), body); !errors.Is(err, ErrNonceStore) {
+		t.Fatalf("got %v, want %v", err, ErrNonceStore)
+	}
+}
@@ -3,6 +3,8 @@ package state
 import (
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/Anti-Raid/jobserver/objectstorage"
@@ -43,7 +45,8 @@ func (t RoundtripJobDl) RoundTrip(req *http.Request) (resp *http.Response, err e
 	url, err := ObjectStorage.GetUrl(
 		req.Context(),
 		objectstorage.GuildBucket(t.guildId),
-		req.URL.Path,
+		// Both job://jobs/ID/file and job:///jobs/ID/file refer to the object at jobs/ID/file
+		strings.TrimPrefix(path.Join(req.URL.Host, req.URL.Path), "/"),
 		"",
 		expiryDuration,
 		true,
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Anti-Raid/jobserver/config"
 	"github.com/Anti-Raid/jobserver/objectstorage"
+	"github.com/Anti-Raid/jobserver/pkg/server/metrics"
 	"github.com/anti-raid/eureka/genconfig"
 	"github.com/anti-raid/eureka/proxy"
 	"github.com/anti-raid/eureka/snippets"
@@ -123,9 +124,9 @@ func Setup() {
 		panic(err)
 	}
 
-	Discord.Client.Transport = proxy.NewHostRewriter(strings.Replace(Config.Meta.Proxy, "http://", "", 1), http.DefaultTransport, func(s string) {
+	Discord.Client.Transport = metrics.NewDiscordTransport(proxy.NewHostRewriter(strings.Replace(Config.Meta.Proxy, "http://", "", 1), http.DefaultTransport, func(s string) {
 		Logger.Info("[PROXY]", zap.String("note", s))
-	})
+	}))
 
 	// Verify token
 	bu, err := Discord.User("@me")
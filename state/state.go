@@ -4,9 +4,13 @@ package state
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"runtime/debug"
+	"time"
 
+	"github.com/Anti-Raid/jobserver/types"
+	"github.com/anti-raid/eureka/jsonimpl"
 	"github.com/bwmarrin/discordgo"
 )
 
@@ -32,8 +36,44 @@ type State interface {
 
 	// GuildID returns the guild ID for the job, if applicable
 	GuildID() string
+
+	// Interrupted returns whether or not the job has been asked to stop at the next safe point
+	// (such as a step boundary) so that it can be resumed later
+	Interrupted() bool
+
+	// ConstraintProvider returns the provider to resolve the constraints of the job with
+	ConstraintProvider() ConstraintProvider
+}
+
+// ConstraintProvider resolves the constraints (limits) jobs of a guild run under, such as from the plan of the guild
+type ConstraintProvider interface {
+	// Resolve resolves the constraints of a kind of job (backups/moderation etc.) for a guild into out, which
+	// must be a pointer to the same type as defaults. Anything not set for the guild is taken from defaults
+	Resolve(ctx context.Context, guildId, kind string, defaults, out any) error
+}
+
+// DefaultConstraintProvider resolves the defaults for every guild
+type DefaultConstraintProvider struct{}
+
+func (DefaultConstraintProvider) Resolve(ctx context.Context, guildId, kind string, defaults, out any) error {
+	b, err := jsonimpl.Marshal(defaults)
+
+	if err != nil {
+		return err
+	}
+
+	return jsonimpl.Unmarshal(b, out)
+}
+
+// StepObserver may optionally be implemented by a State to be notified of every step a job executes
+type StepObserver interface {
+	// ObserveStep is called after a step has executed, err is the error returned by the step, if any
+	ObserveStep(step string, duration time.Duration, err error)
 }
 
+// ErrInterrupted is returned by jobs that stopped early because Interrupted returned true
+var ErrInterrupted = errors.New("job interrupted")
+
 type Progress struct {
 	State string
 	Data  map[string]any
@@ -46,4 +86,9 @@ type ProgressState interface {
 
 	// Sets/demarkates the progress of the job, if supported
 	SetProgress(prog *Progress) error
+
+	// ReportProgress reports how far along the job is for display purposes, if supported
+	//
+	// Unlike SetProgress, this is not used to resume the job and may be rate limited
+	ReportProgress(report types.ProgressReport) error
 }
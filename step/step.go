@@ -7,6 +7,7 @@ package step
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	jobstate "github.com/Anti-Raid/jobserver/state"
 	"github.com/Anti-Raid/jobserver/types"
@@ -105,9 +106,32 @@ func (s *Stepper[T]) Exec(
 		// 2. curProg.State is not empty and is equal to the step state
 		// 3. curProg.State is not empty and is not equal to the step state but the step index is greater than or equal to the current step index
 		if curProg.State == "" || curProg.State == step.State || step.Index >= s.StepIndex(curProg.State) {
+			// Stop at the step boundary, the persisted progress allows the job to be resumed from this step
+			if state.Interrupted() {
+				l.Info("[" + strconv.Itoa(step.Index) + "] Interrupted before step '" + step.State + "'")
+				return nil, jobstate.ErrInterrupted
+			}
+
 			l.Info("[" + strconv.Itoa(step.Index) + "] Executing step '" + step.State + "'")
 
-			outp, prog, err := step.Exec(self, l, state, progstate, curProg)
+			stepProgstate := stepProgressState{
+				ProgressState: progstate,
+				step:          step.State,
+				stepIndex:     i + 1,
+				stepsTotal:    len(s.steps),
+			}
+
+			// Progress reports are only informational, the step may still be executed if they fail
+			if err := stepProgstate.ReportProgress(types.ProgressReport{}); err != nil {
+				l.Warn("Failed to report progress", zap.Error(err))
+			}
+
+			start := time.Now()
+			outp, prog, err := step.Exec(self, l, state, stepProgstate, curProg)
+
+			if o, ok := state.(jobstate.StepObserver); ok {
+				o.ObserveStep(step.State, time.Since(start), err)
+			}
 
 			if err != nil {
 				return nil, err
@@ -160,6 +184,22 @@ func (s *Stepper[T]) Exec(
 	return nil, nil
 }
 
+// stepProgressState fills in the current step of the progress reports made by a step
+type stepProgressState struct {
+	jobstate.ProgressState
+
+	step       string
+	stepIndex  int
+	stepsTotal int
+}
+
+func (s stepProgressState) ReportProgress(report types.ProgressReport) error {
+	report.Step = s.step
+	report.StepIndex = s.stepIndex
+	report.StepsTotal = s.stepsTotal
+	return s.ProgressState.ReportProgress(report)
+}
+
 type Step[T any] struct {
 	State string
 
@@ -13,16 +13,51 @@ type JobCreateResponse struct {
 //
 // Jobs are background processes that can be run on a coordinator server.
 type Job struct {
-	ID        string           `db:"id" json:"id" validate:"required" description:"The ID of the job."`
-	Name      string           `db:"name" json:"name" validate:"required" description:"The name of the job."`
-	Output    *Output          `db:"output" json:"output" description:"The output of the job."`
-	Fields    map[string]any   `db:"fields" json:"fields" description:"The public fields of the job. Note that sensitive data may be omitted from storage entirely"`
-	Statuses  []map[string]any `db:"statuses" json:"statuses" validate:"required" description:"The job statuses."`
-	GuildID   string           `db:"guild_id" json:"guild_id" validate:"required" description:"The ID of the guild the job is for."`
-	Expiry    *time.Duration   `db:"expiry" json:"expiry" validate:"required" description:"The job expiry."`
-	State     string           `db:"state" json:"state" validate:"required" description:"The jobs' current state (pending/completed etc)."`
-	Resumable bool             `db:"resumable" json:"resumable" description:"Whether the job is resumable."`
-	CreatedAt time.Time        `db:"created_at" json:"created_at" description:"The time the job was created."`
+	ID          string           `db:"id" json:"id" validate:"required" description:"The ID of the job."`
+	Name        string           `db:"name" json:"name" validate:"required" description:"The name of the job."`
+	Output      *Output          `db:"output" json:"output" description:"The output of the job."`
+	Fields      map[string]any   `db:"fields" json:"fields" description:"The public fields of the job. Note that sensitive data may be omitted from storage entirely"`
+	Statuses    []map[string]any `db:"statuses" json:"statuses" validate:"required" description:"The job statuses."`
+	GuildID     string           `db:"guild_id" json:"guild_id" validate:"required" description:"The ID of the guild the job is for."`
+	Expiry      *time.Duration   `db:"expiry" json:"expiry" validate:"required" description:"The job expiry."`
+	State       string           `db:"state" json:"state" validate:"required" description:"The jobs' current state (pending/completed etc)."`
+	Resumable   bool             `db:"resumable" json:"resumable" description:"Whether the job is resumable."`
+	CreatedAt   time.Time        `db:"created_at" json:"created_at" description:"The time the job was created."`
+	LastUpdated time.Time        `db:"last_updated" json:"last_updated" description:"The time the job was last updated."`
+	RunAt       *time.Time       `db:"run_at" json:"run_at" description:"The time a scheduled job is executed at, if it was spawned with one."`
+	DependsOn   *string          `db:"depends_on" json:"depends_on" description:"The ID of the job this job waits on, if any."`
+	Attempt     int              `db:"attempt" json:"attempt" description:"The attempt the job is on, starting from 1. Only jobs with a retry policy are retried."`
+	Progress    *ProgressReport  `db:"progress" json:"progress" description:"How far along the job is, if it reports its progress."`
+	Error       *JobError        `db:"error" json:"error" description:"Why the job failed, if it did."`
+	Initiator   *Initiator       `db:"initiator" json:"initiator" description:"Who spawned the job, unset for jobs spawned before initiators were recorded."`
+}
+
+const (
+	// Actions taken through the API without a more specific source
+	InitiatorSourceAPI = "api"
+
+	// Jobs spawned by a schedule
+	InitiatorSourceSchedule = "schedule"
+
+	// Jobs deleted by the jobserver once they expire
+	InitiatorSourceReaper = "reaper"
+)
+
+// Initiator is who (or what) spawned a job or took an action on it
+type Initiator struct {
+	UserID string `json:"user_id,omitempty" description:"The ID of the user who took the action, if a user did."`
+	Source string `json:"source" description:"Where the action was taken from, such as api, schedule or a bot command."`
+}
+
+// ProgressReport is a structured report of how far along a job is
+type ProgressReport struct {
+	Step       string     `json:"step" description:"The step the job is currently on, if any."`
+	StepIndex  int        `json:"step_index" description:"The position of the current step, starting from 1."`
+	StepsTotal int        `json:"steps_total" description:"The total number of steps of the job."`
+	ItemsDone  int        `json:"items_done" description:"The number of items (messages, channels etc.) of the current step that are done."`
+	ItemsTotal int        `json:"items_total" description:"The total number of items of the current step, 0 if unknown."`
+	Percent    float64    `json:"percent" description:"How far along the job is overall, from 0 to 100."`
+	ETA        *time.Time `json:"eta" description:"When the current step is estimated to finish, if known."`
 }
 
 // @ci table=jobs unfilled=1
@@ -36,8 +71,52 @@ type PartialJob struct {
 	CreatedAt time.Time      `db:"created_at" json:"created_at" description:"The time the job was created."`
 }
 
+// JobErrorCode is a machine-readable reason for a job failing
+type JobErrorCode string
+
+const (
+	JobErrorCodeInternal           JobErrorCode = "internal"
+	JobErrorCodeMissingPermissions JobErrorCode = "missing_permissions"
+	JobErrorCodeRateLimited        JobErrorCode = "rate_limited"
+	JobErrorCodeInvalidBackup      JobErrorCode = "invalid_backup"
+	JobErrorCodeBadPassword        JobErrorCode = "bad_password"
+	JobErrorCodeQuotaExceeded      JobErrorCode = "quota_exceeded"
+	JobErrorCodeTimedOut           JobErrorCode = "timed_out"
+)
+
+// JobError is an error returned by a job that can be shown to users
+type JobError struct {
+	Code    JobErrorCode `json:"code" description:"The machine-readable reason for the error, e.g. missing_permissions."`
+	Message string       `json:"message" description:"A message describing the error that is safe to show to users."`
+
+	// The underlying error, this may contain internal details and is hence never stored
+	Err error `json:"-"`
+}
+
+// NewJobError creates a new job error, err may be nil
+func NewJobError(code JobErrorCode, message string, err error) *JobError {
+	return &JobError{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func (e *JobError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
 type JobListResponse struct {
-	Jobs []PartialJob `json:"jobs" description:"The list of (partial) jobs"`
+	Jobs       []PartialJob `json:"jobs" description:"The list of (partial) jobs"`
+	NextCursor *string      `json:"next_cursor" description:"The cursor to fetch the next page of jobs with, if there are more jobs"`
 }
 
 // Output is the output of a job
@@ -46,3 +125,19 @@ type Output struct {
 	Perguild bool          `json:"perguild"`
 	Buffer   *bytes.Buffer `json:"-"`
 }
+
+// @ci table=schedules
+//
+// A schedule spawns a job on a cron expression
+type Schedule struct {
+	ID              string         `db:"id" json:"id" validate:"required" description:"The ID of the schedule."`
+	GuildID         string         `db:"guild_id" json:"guild_id" validate:"required" description:"The ID of the guild the schedule is for."`
+	Name            string         `db:"name" json:"name" validate:"required" description:"The name of the job to spawn."`
+	Data            map[string]any `db:"data" json:"data" validate:"required" description:"The data the job is spawned with."`
+	Cron            string         `db:"cron" json:"cron" validate:"required" description:"The cron expression (in UTC) of the schedule."`
+	MissedRunPolicy string         `db:"missed_run_policy" json:"missed_run_policy" validate:"required" description:"What to do with runs missed while the jobserver was down (run_once/skip)."`
+	NextRunAt       time.Time      `db:"next_run_at" json:"next_run_at" description:"The time the job will next be spawned."`
+	LastRunAt       *time.Time     `db:"last_run_at" json:"last_run_at" description:"The time the job was last spawned."`
+	LastJobID       *string        `db:"last_job_id" json:"last_job_id" description:"The ID of the last job spawned."`
+	CreatedAt       time.Time      `db:"created_at" json:"created_at" description:"The time the schedule was created."`
+}
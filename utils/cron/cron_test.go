@@ -0,0 +1,171 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func bitset(vals ...int) uint64 {
+	var set uint64
+	for _, v := range vals {
+		set |= 1 << v
+	}
+	return set
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field string
+		b     bounds
+		want  uint64
+	}{
+		{"*", hourBounds, bitset(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23)},
+		{"5", minuteBounds, bitset(5)},
+		{"1-5", domBounds, bitset(1, 2, 3, 4, 5)},
+		{"*/15", minuteBounds, bitset(0, 15, 30, 45)},
+		{"5/20", minuteBounds, bitset(5, 25, 45)},
+		{"9-17/4", hourBounds, bitset(9, 13, 17)},
+		{"1,2,10-12", monthBounds, bitset(1, 2, 10, 11, 12)},
+		{"1-3,*/6", hourBounds, bitset(0, 1, 2, 3, 6, 12, 18)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseField(tt.field, tt.b)
+
+		if err != nil {
+			t.Errorf("parseField(%q): unexpected error: %v", tt.field, err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("parseField(%q) = %b, want %b", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr          string
+		dow           uint64
+		domRestricted bool
+		dowRestricted bool
+	}{
+		{"* * * * *", bitset(0, 1, 2, 3, 4, 5, 6), false, false},
+		{"0 0 * * 7", bitset(0), false, true},
+		{"0 0 * * 5-7", bitset(0, 5, 6), false, true},
+		{"0 0 13 * 5", bitset(5), true, true},
+		// Like other cron implementations, a field starting with * counts as unrestricted even with a step
+		{"0 0 */2 * *", bitset(0, 1, 2, 3, 4, 5, 6), false, false},
+		{"@weekly", bitset(0), false, true},
+		{"  @daily  ", bitset(0, 1, 2, 3, 4, 5, 6), false, false},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tt.expr, err)
+			continue
+		}
+
+		if s.dow != tt.dow {
+			t.Errorf("Parse(%q).dow = %b, want %b", tt.expr, s.dow, tt.dow)
+		}
+
+		if s.domRestricted != tt.domRestricted || s.dowRestricted != tt.dowRestricted {
+			t.Errorf("Parse(%q) restricted = (%v, %v), want (%v, %v)", tt.expr, s.domRestricted, s.dowRestricted, tt.domRestricted, tt.dowRestricted)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@fortnightly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", date(2024, 1, 1, 10, 7, 0), date(2024, 1, 1, 10, 8, 0)},
+		{"seconds are truncated", "* * * * *", date(2024, 1, 1, 10, 7, 30), date(2024, 1, 1, 10, 8, 0)},
+		{"step", "*/15 * * * *", date(2024, 1, 1, 10, 7, 0), date(2024, 1, 1, 10, 15, 0)},
+		{"strictly after", "0 * * * *", date(2024, 1, 1, 10, 0, 0), date(2024, 1, 1, 11, 0, 0)},
+		{"next day", "30 9 * * *", date(2024, 1, 1, 10, 0, 0), date(2024, 1, 2, 9, 30, 0)},
+		{"weekdays skip the weekend", "30 9 * * 1-5", date(2024, 1, 5, 10, 0, 0), date(2024, 1, 8, 9, 30, 0)},
+		{"7 is sunday", "0 0 * * 7", date(2024, 1, 1, 0, 0, 0), date(2024, 1, 7, 0, 0, 0)},
+		{"day of month or day of week, day of week first", "0 0 13 * 5", date(2024, 1, 1, 0, 0, 0), date(2024, 1, 5, 0, 0, 0)},
+		{"day of month or day of week, day of month first", "0 0 13 * 5", date(2024, 1, 12, 0, 0, 0), date(2024, 1, 13, 0, 0, 0)},
+		{"unrestricted day of week", "0 0 13 * *", date(2024, 1, 1, 0, 0, 0), date(2024, 1, 13, 0, 0, 0)},
+		{"unrestricted day of month", "0 0 * * 5", date(2024, 1, 6, 0, 0, 0), date(2024, 1, 12, 0, 0, 0)},
+		{"skips short months", "0 0 31 * *", date(2024, 2, 1, 0, 0, 0), date(2024, 3, 31, 0, 0, 0)},
+		{"leap day", "0 0 29 2 *", date(2024, 3, 1, 0, 0, 0), date(2028, 2, 29, 0, 0, 0)},
+		{"next year", "@yearly", date(2024, 6, 1, 0, 0, 0), date(2025, 1, 1, 0, 0, 0)},
+		{"never matches", "0 0 30 2 *", date(2024, 1, 1, 0, 0, 0), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+
+			if err != nil {
+				t.Fatalf("Parse(%q): unexpected error: %v", tt.expr, err)
+			}
+
+			got := s.Next(tt.from)
+
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+
+	s, err := Parse("0 9 * * *")
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := s.Next(time.Date(2024, 1, 1, 10, 0, 0, 0, loc))
+	want := time.Date(2024, 1, 2, 9, 0, 0, 0, loc)
+
+	if !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
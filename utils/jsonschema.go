@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Anti-Raid/jobserver/utils/timex"
+)
+
+var (
+	durationType      = reflect.TypeOf(timex.Duration(0))
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isMarshaler returns whether a type (or a pointer to it) marshals itself, such types are assumed to marshal to strings
+func isMarshaler(t reflect.Type) bool {
+	for _, mt := range []reflect.Type{jsonMarshalerType, textMarshalerType} {
+		if t.Implements(mt) || reflect.PointerTo(t).Implements(mt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JsonFieldName returns the name a struct field is (un)marshalled as, or an empty string if it is ignored
+func JsonFieldName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+	if name == "-" {
+		return ""
+	}
+
+	if name == "" {
+		return f.Name
+	}
+
+	return name
+}
+
+// JsonSchema generates a JSON schema for a type, using the `description` tag of struct fields as descriptions
+func JsonSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		return map[string]any{
+			"type":        []string{"string", "integer"},
+			"description": "A duration such as 1h30m or a number of nanoseconds",
+		}
+	}
+
+	if t == timeType {
+		return map[string]any{
+			"type":   "string",
+			"format": "date-time",
+		}
+	}
+
+	// Checked before the kind, a struct marshalling itself has none of the properties of its fields
+	if isMarshaler(t) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": JsonSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": JsonSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		properties := map[string]any{}
+
+		for _, f := range reflect.VisibleFields(t) {
+			if f.Anonymous {
+				continue
+			}
+
+			name := JsonFieldName(f)
+
+			if name == "" {
+				continue
+			}
+
+			schema := JsonSchema(f.Type)
+
+			if description := f.Tag.Get("description"); description != "" {
+				schema["description"] = description
+			}
+
+			properties[name] = schema
+		}
+
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	default:
+		// Interfaces etc. can be anything
+		return map[string]any{}
+	}
+}